@@ -0,0 +1,61 @@
+package pg
+
+import (
+	"context"
+	"sync"
+)
+
+// BindingKind identifies what kind of gateway identifier a binding refers to
+type BindingKind string
+
+const (
+	BindingOrder   BindingKind = "order"   // a GatewayOrderID
+	BindingPayment BindingKind = "payment" // a GatewayPaymentID
+)
+
+// BindingStore records which gateway created an order or payment so that
+// follow-up calls are routed to the same gateway even after the resolver
+// starts returning a different one.
+//
+// Implementations must be safe for concurrent use. A SQL implementation maps
+// naturally onto a table keyed by (kind, id) with a gateway column.
+type BindingStore interface {
+	// Bind records that id of the given kind belongs to gateway.
+	Bind(ctx context.Context, kind BindingKind, id, gateway string) error
+
+	// Lookup returns the gateway bound to id, and false if there is no binding.
+	Lookup(ctx context.Context, kind BindingKind, id string) (gateway string, found bool, err error)
+}
+
+type bindingKey struct {
+	kind BindingKind
+	id   string
+}
+
+// MemoryBindingStore is an in-process BindingStore. Bindings are lost on
+// restart, so multi-instance deployments should use a shared store instead.
+type MemoryBindingStore struct {
+	mu       sync.RWMutex
+	bindings map[bindingKey]string
+}
+
+// NewMemoryBindingStore creates an empty MemoryBindingStore.
+func NewMemoryBindingStore() *MemoryBindingStore {
+	return &MemoryBindingStore{bindings: make(map[bindingKey]string)}
+}
+
+// Bind implements BindingStore.
+func (m *MemoryBindingStore) Bind(_ context.Context, kind BindingKind, id, gateway string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bindings[bindingKey{kind, id}] = gateway
+	return nil
+}
+
+// Lookup implements BindingStore.
+func (m *MemoryBindingStore) Lookup(_ context.Context, kind BindingKind, id string) (string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	gw, ok := m.bindings[bindingKey{kind, id}]
+	return gw, ok, nil
+}
@@ -0,0 +1,275 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a per-gateway circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // calls flow normally
+	CircuitOpen     CircuitState = "open"      // calls fail fast without reaching the gateway
+	CircuitHalfOpen CircuitState = "half_open" // a limited number of probe calls are let through
+)
+
+// ErrCircuitOpen is matched (via errors.Is) by errors returned when a call is
+// rejected because the gateway's circuit is open.
+var ErrCircuitOpen = errors.New("pg-switcher: circuit open")
+
+// CircuitOpenError is returned instead of calling a gateway whose circuit is
+// open. It is retriable, so failover moves on to the next gateway.
+type CircuitOpenError struct {
+	Gateway string
+	Since   time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("pg-switcher: circuit open for gateway %q since %s", e.Gateway, e.Since.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool { return target == ErrCircuitOpen }
+func (e *CircuitOpenError) Retriable() bool      { return true }
+
+// CircuitStateChange describes a breaker transition passed to OnStateChange
+type CircuitStateChange struct {
+	Gateway     string
+	From        CircuitState
+	To          CircuitState
+	At          time.Time
+	FailureRate float64 // failure rate of the rolling window at the time of the transition
+}
+
+// GatewayHealth is a snapshot of a gateway's breaker, e.g. for an admin panel
+type GatewayHealth struct {
+	Gateway     string
+	State       CircuitState
+	Since       time.Time // when the breaker entered State
+	FailureRate float64   // failures / requests in the rolling window
+	Requests    int       // number of outcomes currently in the rolling window
+}
+
+// BreakerConfig configures the per-gateway circuit breakers. Zero fields take
+// the defaults noted below. Only retriable errors (see IsRetriable) count as
+// failures; a rejected request says nothing about the gateway's health. Calls
+// whose context was cancelled or timed out are not counted at all.
+type BreakerConfig struct {
+	WindowSize       int           // outcomes kept in the rolling window (default 20)
+	MinRequests      int           // outcomes needed before the breaker may open (default 10)
+	FailureRate      float64       // failure rate at which the breaker opens (default 0.5)
+	OpenTimeout      time.Duration // time spent open before probing (default 30s)
+	HalfOpenMaxCalls int           // concurrent probes in half-open; all must succeed to close (default 1)
+
+	// OnStateChange is called after every transition, outside the breaker lock
+	OnStateChange func(CircuitStateChange)
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.WindowSize <= 0 {
+		c.WindowSize = 20
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.MinRequests > c.WindowSize {
+		c.MinRequests = c.WindowSize
+	}
+	if c.FailureRate <= 0 {
+		c.FailureRate = 0.5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenMaxCalls <= 0 {
+		c.HalfOpenMaxCalls = 1
+	}
+	return c
+}
+
+// WithCircuitBreaker enables a circuit breaker per registered gateway. An open
+// gateway fails fast with a *CircuitOpenError and is skipped by failover.
+func WithCircuitBreaker(cfg BreakerConfig) SwitcherOption {
+	return func(o *switcherOptions) { o.breakers = newBreakerSet(cfg.withDefaults()) }
+}
+
+// breakerSet holds one breaker per gateway name, created on first use
+type breakerSet struct {
+	cfg      BreakerConfig
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func newBreakerSet(cfg BreakerConfig) *breakerSet {
+	return &breakerSet{cfg: cfg, breakers: make(map[string]*breaker)}
+}
+
+func (bs *breakerSet) get(name string) *breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.breakers[name]
+	if !ok {
+		b = &breaker{
+			name:   name,
+			cfg:    bs.cfg,
+			state:  CircuitClosed,
+			since:  time.Now(),
+			window: make([]bool, bs.cfg.WindowSize),
+		}
+		bs.breakers[name] = b
+	}
+	return b
+}
+
+// reset drops the breaker for name; the next call starts a closed one.
+func (bs *breakerSet) reset(name string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	delete(bs.breakers, name)
+}
+
+// breaker tracks a rolling window of call outcomes for one gateway
+type breaker struct {
+	name string
+	cfg  BreakerConfig
+
+	mu        sync.Mutex
+	state     CircuitState
+	since     time.Time
+	window    []bool // true = failure
+	pos       int
+	count     int
+	failures  int
+	probes    int    // in-flight half-open calls
+	successes int    // successful half-open calls
+	gen       uint64 // incremented on every transition
+}
+
+// allow reports whether a call may proceed, moving an expired open breaker to
+// half-open. It returns the generation the call was admitted under, which
+// must be passed to record.
+func (b *breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	var change *CircuitStateChange
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.since) < b.cfg.OpenTimeout {
+			since := b.since
+			b.mu.Unlock()
+			return 0, &CircuitOpenError{Gateway: b.name, Since: since}
+		}
+		change = b.transition(CircuitHalfOpen)
+		b.probes++
+	case CircuitHalfOpen:
+		if b.probes >= b.cfg.HalfOpenMaxCalls {
+			since := b.since
+			b.mu.Unlock()
+			return 0, &CircuitOpenError{Gateway: b.name, Since: since}
+		}
+		b.probes++
+	}
+	gen := b.gen
+	b.mu.Unlock()
+	b.notify(change)
+	return gen, nil
+}
+
+// record feeds the outcome of an allowed call back into the breaker. Outcomes
+// of calls admitted before the last transition are ignored, so that a slow
+// call let through while closed cannot settle a half-open breaker. A probe
+// that fails with a non-retriable error, e.g. a rejected request, proves
+// nothing either way: it frees its slot without counting toward closing.
+func (b *breaker) record(gen uint64, err error) {
+	failed := IsRetriable(err)
+	b.mu.Lock()
+	if gen != b.gen {
+		b.mu.Unlock()
+		return
+	}
+	var change *CircuitStateChange
+	switch b.state {
+	case CircuitClosed:
+		b.push(failed)
+		if b.count >= b.cfg.MinRequests && b.rate() >= b.cfg.FailureRate {
+			change = b.transition(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		b.probes--
+		if failed {
+			change = b.transition(CircuitOpen)
+		} else if err == nil {
+			if b.successes++; b.successes >= b.cfg.HalfOpenMaxCalls {
+				change = b.transition(CircuitClosed)
+			}
+		}
+	}
+	b.mu.Unlock()
+	b.notify(change)
+}
+
+// release returns an allowed call without an outcome, for calls the caller
+// abandoned (its context was cancelled or timed out) before the gateway
+// answered.
+func (b *breaker) release(gen uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen == b.gen && b.state == CircuitHalfOpen {
+		b.probes--
+	}
+}
+
+func (b *breaker) push(failed bool) {
+	if b.count == len(b.window) {
+		if b.window[b.pos] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.window[b.pos] = failed
+	if failed {
+		b.failures++
+	}
+	b.pos = (b.pos + 1) % len(b.window)
+}
+
+func (b *breaker) rate() float64 {
+	if b.count == 0 {
+		return 0
+	}
+	return float64(b.failures) / float64(b.count)
+}
+
+// transition must be called with b.mu held
+func (b *breaker) transition(to CircuitState) *CircuitStateChange {
+	change := &CircuitStateChange{Gateway: b.name, From: b.state, To: to, At: time.Now(), FailureRate: b.rate()}
+	b.state = to
+	b.since = change.At
+	b.gen++
+	b.probes = 0
+	b.successes = 0
+	if to == CircuitClosed {
+		b.pos, b.count, b.failures = 0, 0, 0
+	}
+	return change
+}
+
+func (b *breaker) notify(change *CircuitStateChange) {
+	if change != nil && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(*change)
+	}
+}
+
+func (b *breaker) snapshot() GatewayHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return GatewayHealth{
+		Gateway:     b.name,
+		State:       b.state,
+		Since:       b.since,
+		FailureRate: b.rate(),
+		Requests:    b.count,
+	}
+}
@@ -0,0 +1,269 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errUnavailable = &GatewayError{Gateway: "test", Kind: ErrGatewayUnavailable, Retriable: true}
+
+func newTestBreaker(cfg BreakerConfig) *breaker {
+	return newBreakerSet(cfg.withDefaults()).get("test")
+}
+
+// expire makes an open breaker's timeout elapse.
+func (b *breaker) expire() {
+	b.mu.Lock()
+	b.since = b.since.Add(-b.cfg.OpenTimeout)
+	b.mu.Unlock()
+}
+
+// run admits a call and records err, failing the test if it was rejected.
+func run(t *testing.T, b *breaker, err error) {
+	t.Helper()
+	gen, allowErr := b.allow()
+	if allowErr != nil {
+		t.Fatalf("allow() = %v, want nil", allowErr)
+	}
+	b.record(gen, err)
+}
+
+func TestBreakerStateMachine(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps func(t *testing.T, b *breaker)
+		want  CircuitState
+	}{
+		{
+			name: "stays closed below MinRequests",
+			steps: func(t *testing.T, b *breaker) {
+				for i := 0; i < 3; i++ {
+					run(t, b, errUnavailable)
+				}
+			},
+			want: CircuitClosed,
+		},
+		{
+			name: "opens at the failure rate",
+			steps: func(t *testing.T, b *breaker) {
+				run(t, b, nil)
+				run(t, b, nil)
+				run(t, b, errUnavailable)
+				run(t, b, errUnavailable)
+			},
+			want: CircuitOpen,
+		},
+		{
+			name: "ignores non-retriable errors",
+			steps: func(t *testing.T, b *breaker) {
+				for i := 0; i < 4; i++ {
+					run(t, b, ErrInvalidRequest)
+				}
+			},
+			want: CircuitClosed,
+		},
+		{
+			name: "half-opens after the timeout",
+			steps: func(t *testing.T, b *breaker) {
+				for i := 0; i < 4; i++ {
+					run(t, b, errUnavailable)
+				}
+				b.expire()
+				if _, err := b.allow(); err != nil {
+					t.Fatalf("allow() after timeout = %v", err)
+				}
+			},
+			want: CircuitHalfOpen,
+		},
+		{
+			name: "closes after a successful probe",
+			steps: func(t *testing.T, b *breaker) {
+				for i := 0; i < 4; i++ {
+					run(t, b, errUnavailable)
+				}
+				b.expire()
+				run(t, b, nil)
+			},
+			want: CircuitClosed,
+		},
+		{
+			name: "reopens after a failed probe",
+			steps: func(t *testing.T, b *breaker) {
+				for i := 0; i < 4; i++ {
+					run(t, b, errUnavailable)
+				}
+				b.expire()
+				run(t, b, errUnavailable)
+			},
+			want: CircuitOpen,
+		},
+		{
+			name: "stays half-open after a non-retriable probe error",
+			steps: func(t *testing.T, b *breaker) {
+				for i := 0; i < 4; i++ {
+					run(t, b, errUnavailable)
+				}
+				b.expire()
+				run(t, b, ErrInvalidRequest)
+				run(t, b, context.Canceled) // the slot was freed
+			},
+			want: CircuitHalfOpen,
+		},
+		{
+			name: "stays half-open after a released probe",
+			steps: func(t *testing.T, b *breaker) {
+				for i := 0; i < 4; i++ {
+					run(t, b, errUnavailable)
+				}
+				b.expire()
+				gen, err := b.allow()
+				if err != nil {
+					t.Fatal(err)
+				}
+				b.release(gen)
+				if _, err := b.allow(); err != nil {
+					t.Fatalf("allow() after release = %v, want the slot freed", err)
+				}
+			},
+			want: CircuitHalfOpen,
+		},
+		{
+			name: "ignores calls admitted before the last transition",
+			steps: func(t *testing.T, b *breaker) {
+				late, err := b.allow()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 4; i++ {
+					run(t, b, errUnavailable)
+				}
+				b.expire()
+				if _, err := b.allow(); err != nil {
+					t.Fatal(err)
+				}
+				b.record(late, nil) // must not close the half-open breaker
+			},
+			want: CircuitHalfOpen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker(BreakerConfig{WindowSize: 4, MinRequests: 4, FailureRate: 0.5, OpenTimeout: time.Minute})
+			tt.steps(t, b)
+			if got := b.snapshot().State; got != tt.want {
+				t.Fatalf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerRejectsWhileOpen(t *testing.T) {
+	b := newTestBreaker(BreakerConfig{WindowSize: 2, MinRequests: 2, OpenTimeout: time.Minute})
+	run(t, b, errUnavailable)
+	run(t, b, errUnavailable)
+
+	_, err := b.allow()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) || !IsRetriable(err) {
+		t.Fatalf("allow() while open = %v, want a retriable *CircuitOpenError", err)
+	}
+}
+
+func TestBreakerLimitsHalfOpenProbes(t *testing.T) {
+	b := newTestBreaker(BreakerConfig{WindowSize: 2, MinRequests: 2, OpenTimeout: time.Minute, HalfOpenMaxCalls: 2})
+	run(t, b, errUnavailable)
+	run(t, b, errUnavailable)
+	b.expire()
+
+	first, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("third probe: allow() = %v, want ErrCircuitOpen", err)
+	}
+	b.record(first, nil)
+	if got := b.snapshot().State; got != CircuitHalfOpen {
+		t.Fatalf("after one of two probes: state = %s, want %s", got, CircuitHalfOpen)
+	}
+	b.record(second, nil)
+	if got := b.snapshot().State; got != CircuitClosed {
+		t.Fatalf("after both probes: state = %s, want %s", got, CircuitClosed)
+	}
+}
+
+func TestBreakerReportsTransitions(t *testing.T) {
+	var changes []CircuitStateChange
+	b := newTestBreaker(BreakerConfig{WindowSize: 2, MinRequests: 2, OpenTimeout: time.Minute,
+		OnStateChange: func(c CircuitStateChange) { changes = append(changes, c) }})
+	run(t, b, errUnavailable)
+	run(t, b, errUnavailable)
+	b.expire()
+	run(t, b, nil)
+
+	want := []struct{ from, to CircuitState }{
+		{CircuitClosed, CircuitOpen},
+		{CircuitOpen, CircuitHalfOpen},
+		{CircuitHalfOpen, CircuitClosed},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d transitions, want %d: %+v", len(changes), len(want), changes)
+	}
+	for i, w := range want {
+		if changes[i].From != w.from || changes[i].To != w.to || changes[i].Gateway != "test" {
+			t.Errorf("transition %d = %+v, want %s -> %s", i, changes[i], w.from, w.to)
+		}
+	}
+}
+
+// downGateway fails every order as unavailable, or blocks until the caller
+// gives up when block is set.
+type downGateway struct {
+	orderGateway
+	block bool
+}
+
+func (g *downGateway) CreateOrder(ctx context.Context, _ CreateOrderRequest) (*CreateOrderResponse, error) {
+	g.calls.Add(1)
+	if g.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, errUnavailable
+}
+
+func TestBreakerIgnoresAbandonedCalls(t *testing.T) {
+	gw := &downGateway{}
+	s := NewDynamicPaymentSwitcher(map[string]PaymentGateway{"test": gw},
+		func(context.Context) (string, error) { return "test", nil },
+		WithCircuitBreaker(BreakerConfig{WindowSize: 2, MinRequests: 2, OpenTimeout: time.Minute}))
+	for i := 0; i < 2; i++ {
+		s.CreateOrder(context.Background(), CreateOrderRequest{})
+	}
+	s.opts.breakers.get("test").expire()
+
+	// The probe outlives its caller; the gateway is no healthier for it
+	gw.block = true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := s.CreateOrder(ctx, CreateOrderRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CreateOrder = %v, want context.DeadlineExceeded", err)
+	}
+	if got := s.opts.breakers.get("test").snapshot().State; got != CircuitHalfOpen {
+		t.Fatalf("state = %s, want %s", got, CircuitHalfOpen)
+	}
+
+	gw.block = false
+	if _, err := s.CreateOrder(context.Background(), CreateOrderRequest{}); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("next probe rejected: the abandoned probe kept its slot")
+	}
+	if got := s.opts.breakers.get("test").snapshot().State; got != CircuitOpen {
+		t.Fatalf("state after a failed probe = %s, want %s", got, CircuitOpen)
+	}
+}
@@ -0,0 +1,86 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+// CaptureRequest contains fields for capturing an authorized payment
+type CaptureRequest struct {
+	GatewayOrderID   string // used to find the authorized payment when GatewayPaymentID is empty
+	GatewayPaymentID string
+	Amount           int64  // in the currency's minor unit; 0 captures the full authorized amount
+	Currency         string // defaults to the payment's currency
+}
+
+// Money returns Amount and Currency as Money.
+func (r CaptureRequest) Money() Money { return Money{Amount: r.Amount, Currency: r.Currency} }
+
+// VoidRequest identifies an authorized payment whose hold should be released
+type VoidRequest struct {
+	GatewayOrderID   string // used to find the authorized payment when GatewayPaymentID is empty
+	GatewayPaymentID string
+}
+
+// PaymentCapturer is implemented by payment adapters that support
+// authorize-only orders (CreateOrderRequest.AuthorizeOnly). Payments of such
+// orders stop at PaymentStateAuthorized until they are captured or voided.
+// The dynamic and tenant switchers implement it for every registered
+// gateway, failing with ErrNotImplemented when the resolved adapter does not.
+type PaymentCapturer interface {
+	// CapturePayment captures all or part of an authorized payment.
+	CapturePayment(ctx context.Context, req CaptureRequest) (*PaymentStatus, error)
+
+	// VoidPayment releases an authorized payment without capturing it.
+	VoidPayment(ctx context.Context, req VoidRequest) (*PaymentStatus, error)
+}
+
+// manualCaptureReporter is implemented by wrappers, which implement
+// PaymentCapturer whatever they wrap, to report whether the wrapped gateway
+// does.
+type manualCaptureReporter interface {
+	supportsManualCapture() bool
+}
+
+// supportsManualCapture reports whether gw implements PaymentCapturer,
+// looking through wrappers such as WrapPaymentGateway and
+// NewIdempotentPaymentGateway.
+func supportsManualCapture(gw interface{}) bool {
+	if r, ok := gw.(manualCaptureReporter); ok {
+		return r.supportsManualCapture()
+	}
+	_, ok := gw.(PaymentCapturer)
+	return ok
+}
+
+// paymentCapturer returns gw as a PaymentCapturer, or an error wrapping
+// ErrNotImplemented naming the gateway.
+func paymentCapturer(name string, gw interface{}) (PaymentCapturer, error) {
+	c, ok := gw.(PaymentCapturer)
+	if !ok || !supportsManualCapture(gw) {
+		return nil, fmt.Errorf("pg: %s: manual capture: %w", name, ErrNotImplemented)
+	}
+	return c, nil
+}
+
+// capturePaymentFunc and voidPaymentFunc call gw's PaymentCapturer methods,
+// failing with ErrNotImplemented when gw has none.
+func capturePaymentFunc(gw interface{}, name string) func(context.Context, CaptureRequest) (*PaymentStatus, error) {
+	return func(ctx context.Context, req CaptureRequest) (*PaymentStatus, error) {
+		c, err := paymentCapturer(name, gw)
+		if err != nil {
+			return nil, err
+		}
+		return c.CapturePayment(ctx, req)
+	}
+}
+
+func voidPaymentFunc(gw interface{}, name string) func(context.Context, VoidRequest) (*PaymentStatus, error) {
+	return func(ctx context.Context, req VoidRequest) (*PaymentStatus, error) {
+		c, err := paymentCapturer(name, gw)
+		if err != nil {
+			return nil, err
+		}
+		return c.VoidPayment(ctx, req)
+	}
+}
@@ -0,0 +1,61 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// capturingGateway is an orderGateway that supports manual capture.
+type capturingGateway struct{ orderGateway }
+
+func (g *capturingGateway) CapturePayment(context.Context, CaptureRequest) (*PaymentStatus, error) {
+	return &PaymentStatus{State: PaymentStateCaptured}, nil
+}
+
+func (g *capturingGateway) VoidPayment(context.Context, VoidRequest) (*PaymentStatus, error) {
+	return &PaymentStatus{State: PaymentStateFailed}, nil
+}
+
+func TestAuthorizeOnlyLooksThroughWrappers(t *testing.T) {
+	idempotent := func(gw PaymentGateway) PaymentGateway {
+		return NewIdempotentPaymentGateway(gw, NewMemoryIdempotencyStore(), time.Hour)
+	}
+	wrap := func(gw PaymentGateway) PaymentGateway { return WrapPaymentGateway(gw) }
+	tests := []struct {
+		name    string
+		capture bool
+		wrap    func(PaymentGateway) PaymentGateway
+	}{
+		{"adapter", false, func(gw PaymentGateway) PaymentGateway { return gw }},
+		{"intercepted", false, wrap},
+		{"idempotent", false, idempotent},
+		{"nested", false, func(gw PaymentGateway) PaymentGateway { return wrap(idempotent(gw)) }},
+		{"capturing adapter", true, func(gw PaymentGateway) PaymentGateway { return gw }},
+		{"capturing nested", true, func(gw PaymentGateway) PaymentGateway { return wrap(idempotent(gw)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inner PaymentGateway = &orderGateway{}
+			calls := &inner.(*orderGateway).calls
+			if tt.capture {
+				c := &capturingGateway{}
+				inner, calls = c, &c.calls
+			}
+			s := NewDynamicPaymentSwitcher(map[string]PaymentGateway{"test": tt.wrap(inner)},
+				func(context.Context) (string, error) { return "test", nil })
+
+			_, err := s.CreateOrder(context.Background(), CreateOrderRequest{Amount: 100, Currency: "INR", Receipt: "bk_1", AuthorizeOnly: true})
+			if tt.capture {
+				if err != nil || calls.Load() != 1 {
+					t.Fatalf("CreateOrder = %v after %d calls, want one successful call", err, calls.Load())
+				}
+				return
+			}
+			if !errors.Is(err, ErrNotImplemented) || calls.Load() != 0 {
+				t.Fatalf("CreateOrder = %v after %d calls, want ErrNotImplemented before reaching the gateway", err, calls.Load())
+			}
+		})
+	}
+}
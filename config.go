@@ -1,37 +1,88 @@
 package pg
 
+import "net/http"
+
 // RazorpayConfig holds Razorpay payment gateway credentials
 type RazorpayConfig struct {
-	KeyID         string
-	KeySecret     string
-	WebhookSecret string
+	KeyID         string `json:"key_id" yaml:"key_id"`
+	KeySecret     string `json:"key_secret" yaml:"key_secret"`
+	WebhookSecret string `json:"webhook_secret" yaml:"webhook_secret"`
+
+	// WebhookSecrets are accepted in addition to WebhookSecret, e.g. the new
+	// secret while a rotation is in progress.
+	WebhookSecrets []WebhookSecret `json:"webhook_secrets,omitempty" yaml:"webhook_secrets,omitempty"`
+
+	// Secrets, when set, is consulted on every call for the credentials above;
+	// values it does not have fall back to these fields.
+	Secrets SecretProvider `json:"-" yaml:"-"`
+	// OnSecretError, when set, is called when Secrets fails while a webhook
+	// is verified; the webhook secret above is used instead.
+	OnSecretError func(name string, err error) `json:"-" yaml:"-"`
 }
 
 // RazorpayXConfig holds RazorpayX payout gateway credentials
 type RazorpayXConfig struct {
-	KeyID         string
-	KeySecret     string
-	AccountNumber string
-	WebhookSecret string
+	KeyID         string `json:"key_id" yaml:"key_id"`
+	KeySecret     string `json:"key_secret" yaml:"key_secret"`
+	AccountNumber string `json:"account_number" yaml:"account_number"`
+	WebhookSecret string `json:"webhook_secret" yaml:"webhook_secret"`
+
+	// WebhookSecrets are accepted in addition to WebhookSecret, e.g. the new
+	// secret while a rotation is in progress.
+	WebhookSecrets []WebhookSecret `json:"webhook_secrets,omitempty" yaml:"webhook_secrets,omitempty"`
+
+	// Secrets, when set, is consulted on every call for the credentials above;
+	// values it does not have fall back to these fields.
+	Secrets SecretProvider `json:"-" yaml:"-"`
+	// OnSecretError, when set, is called when Secrets fails while a webhook
+	// is verified; the webhook secret above is used instead.
+	OnSecretError func(name string, err error) `json:"-" yaml:"-"`
 }
 
 // PaytmConfig holds Paytm payment gateway credentials
 type PaytmConfig struct {
-	MID         string // Merchant ID
-	MerchantKey string
-	Website     string // e.g. "WEBSTAGING" or "DEFAULT"
-	CallbackURL string
-	WebhookSecret string
-	Production  bool
+	MID           string `json:"mid" yaml:"mid"` // Merchant ID
+	MerchantKey   string `json:"merchant_key" yaml:"merchant_key"`
+	Website       string `json:"website" yaml:"website"` // e.g. "WEBSTAGING" or "DEFAULT"
+	CallbackURL   string `json:"callback_url" yaml:"callback_url"`
+	WebhookSecret string `json:"webhook_secret" yaml:"webhook_secret"`
+
+	// WebhookSecrets are accepted in addition to WebhookSecret, e.g. the new
+	// secret while a rotation is in progress.
+	WebhookSecrets []WebhookSecret `json:"webhook_secrets,omitempty" yaml:"webhook_secrets,omitempty"`
+	Production     bool            `json:"production" yaml:"production"`
+
+	// HTTPClient is used for all Paytm API calls; nil means a new http.Client.
+	// Supply an instrumented client (e.g. from otelpg.HTTPClient) for tracing.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+
+	// Secrets, when set, is consulted on every call for MerchantKey and
+	// WebhookSecret; values it does not have fall back to those fields.
+	Secrets SecretProvider `json:"-" yaml:"-"`
+	// OnSecretError, when set, is called when Secrets fails while a webhook
+	// is verified; the webhook secret above is used instead.
+	OnSecretError func(name string, err error) `json:"-" yaml:"-"`
 }
 
 // Config aggregates all gateway credentials and selects which gateway to use
 type Config struct {
-	// Gateway selection (used by NewPayment / NewPayout static constructors)
-	PaymentGateway string // "razorpay" | "paytm"
-	PayoutGateway  string // "razorpayx" | "paytm" | "manual"
+	// Gateway selection, used by NewPayment / NewPayout and as the default
+	// gateway of the FromConfig switcher constructors
+	PaymentGateway string `json:"payment_gateway" yaml:"payment_gateway"` // "razorpay" | "paytm"
+	PayoutGateway  string `json:"payout_gateway" yaml:"payout_gateway"`   // "razorpayx" | "paytm" | "manual"
+
+	// Production marks a live deployment; Validate then rejects test-mode
+	// credentials such as rzp_test_ keys and Paytm staging settings.
+	Production bool `json:"production" yaml:"production"`
+
+	// Secrets is used by every gateway section that has no Secrets of its own.
+	Secrets SecretProvider `json:"-" yaml:"-"`
+
+	// OnSecretError is used by every gateway section that has no
+	// OnSecretError of its own.
+	OnSecretError func(name string, err error) `json:"-" yaml:"-"`
 
-	Razorpay  RazorpayConfig
-	RazorpayX RazorpayXConfig
-	Paytm     PaytmConfig
+	Razorpay  RazorpayConfig  `json:"razorpay" yaml:"razorpay"`
+	RazorpayX RazorpayXConfig `json:"razorpayx" yaml:"razorpayx"`
+	Paytm     PaytmConfig     `json:"paytm" yaml:"paytm"`
 }
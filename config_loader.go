@@ -0,0 +1,236 @@
+package pg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFromEnv reads a Config from environment variables named prefix
+// followed by the field name, e.g. with prefix "PG_":
+//
+//	PG_PAYMENT_GATEWAY, PG_PAYOUT_GATEWAY, PG_PRODUCTION
+//	PG_RAZORPAY_KEY_ID, PG_RAZORPAY_KEY_SECRET, PG_RAZORPAY_WEBHOOK_SECRET
+//	PG_RAZORPAYX_KEY_ID, PG_RAZORPAYX_KEY_SECRET, PG_RAZORPAYX_ACCOUNT_NUMBER,
+//	PG_RAZORPAYX_WEBHOOK_SECRET
+//	PG_PAYTM_MID, PG_PAYTM_MERCHANT_KEY, PG_PAYTM_WEBSITE, PG_PAYTM_CALLBACK_URL,
+//	PG_PAYTM_WEBHOOK_SECRET, PG_PAYTM_PRODUCTION
+//
+// Unset variables leave the field empty. The result is validated.
+func LoadConfigFromEnv(prefix string) (*Config, error) {
+	var cfg Config
+	var errs []error
+	str := func(name string, dst *string) {
+		*dst = os.Getenv(prefix + name)
+	}
+	boolean := func(name string, dst *bool) {
+		v, ok := os.LookupEnv(prefix + name)
+		if !ok || v == "" {
+			return
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: not a boolean", prefix, name))
+			return
+		}
+		*dst = b
+	}
+
+	str("PAYMENT_GATEWAY", &cfg.PaymentGateway)
+	str("PAYOUT_GATEWAY", &cfg.PayoutGateway)
+	boolean("PRODUCTION", &cfg.Production)
+
+	str("RAZORPAY_KEY_ID", &cfg.Razorpay.KeyID)
+	str("RAZORPAY_KEY_SECRET", &cfg.Razorpay.KeySecret)
+	str("RAZORPAY_WEBHOOK_SECRET", &cfg.Razorpay.WebhookSecret)
+
+	str("RAZORPAYX_KEY_ID", &cfg.RazorpayX.KeyID)
+	str("RAZORPAYX_KEY_SECRET", &cfg.RazorpayX.KeySecret)
+	str("RAZORPAYX_ACCOUNT_NUMBER", &cfg.RazorpayX.AccountNumber)
+	str("RAZORPAYX_WEBHOOK_SECRET", &cfg.RazorpayX.WebhookSecret)
+
+	str("PAYTM_MID", &cfg.Paytm.MID)
+	str("PAYTM_MERCHANT_KEY", &cfg.Paytm.MerchantKey)
+	str("PAYTM_WEBSITE", &cfg.Paytm.Website)
+	str("PAYTM_CALLBACK_URL", &cfg.Paytm.CallbackURL)
+	str("PAYTM_WEBHOOK_SECRET", &cfg.Paytm.WebhookSecret)
+	boolean("PAYTM_PRODUCTION", &cfg.Paytm.Production)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("pg: load config from env: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadConfigJSON reads and validates a JSON config file.
+func LoadConfigJSON(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("pg: load config: %w", err)
+	}
+	return ParseConfigJSON(data)
+}
+
+// LoadConfigYAML reads and validates a YAML config file.
+func LoadConfigYAML(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("pg: load config: %w", err)
+	}
+	return ParseConfigYAML(data)
+}
+
+// ParseConfigJSON decodes and validates a JSON config. Unknown keys are
+// rejected so that typos do not silently drop credentials.
+func ParseConfigJSON(data []byte) (*Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("pg: parse config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ParseConfigYAML decodes and validates a YAML config. Unknown keys are
+// rejected so that typos do not silently drop credentials.
+func ParseConfigYAML(data []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("pg: parse config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the config and reports all problems at once. Messages name
+// the offending fields but never include their values.
+//
+// A gateway section that has any field set must have all of its credentials;
+// secrets may instead come from a SecretProvider.
+// The selected PaymentGateway and PayoutGateway must be built-in gateways with
+// credentials, or the names of registered drivers. With Production set,
+// rzp_test_ keys, Paytm staging and the WEBSTAGING website are rejected.
+func (c *Config) Validate() error {
+	var errs []error
+	missing := func(section string, fields ...string) {
+		errs = append(errs, fmt.Errorf("%s: missing %s", section, strings.Join(fields, ", ")))
+	}
+
+	// Secrets held by a SecretProvider need not be in the config itself
+	secret := func(value string, p SecretProvider) string {
+		if p != nil || c.Secrets != nil {
+			return "(provided)"
+		}
+		return value
+	}
+
+	rzp, rzpx, paytm := c.Razorpay, c.RazorpayX, c.Paytm
+	if rzp.KeyID != "" || rzp.KeySecret != "" || rzp.WebhookSecret != "" {
+		if f := missingFields(map[string]string{"key_id": rzp.KeyID, "key_secret": secret(rzp.KeySecret, rzp.Secrets)}); len(f) > 0 {
+			missing("razorpay", f...)
+		}
+	}
+	if rzpx.KeyID != "" || rzpx.KeySecret != "" || rzpx.AccountNumber != "" || rzpx.WebhookSecret != "" {
+		if f := missingFields(map[string]string{"key_id": rzpx.KeyID, "key_secret": secret(rzpx.KeySecret, rzpx.Secrets), "account_number": rzpx.AccountNumber}); len(f) > 0 {
+			missing("razorpayx", f...)
+		}
+	}
+	if paytm.MID != "" || paytm.MerchantKey != "" || paytm.Website != "" || paytm.CallbackURL != "" || paytm.WebhookSecret != "" {
+		if f := missingFields(map[string]string{"mid": paytm.MID, "merchant_key": secret(paytm.MerchantKey, paytm.Secrets)}); len(f) > 0 {
+			missing("paytm", f...)
+		}
+	}
+
+	for _, ws := range []struct {
+		section string
+		list    []WebhookSecret
+	}{
+		{"razorpay", rzp.WebhookSecrets},
+		{"razorpayx", rzpx.WebhookSecrets},
+		{"paytm", paytm.WebhookSecrets},
+	} {
+		for i, secret := range ws.list {
+			if f := missingFields(map[string]string{"id": secret.ID, "value": secret.Value}); len(f) > 0 {
+				missing(fmt.Sprintf("%s: webhook_secrets[%d]", ws.section, i), f...)
+			}
+		}
+	}
+
+	configured := map[string]bool{
+		"razorpay":  rzp.KeyID != "",
+		"razorpayx": rzpx.KeyID != "",
+		"paytm":     paytm.MID != "",
+		"manual":    true,
+	}
+	builtin := map[string]map[string]bool{
+		"payment_gateway": {"razorpay": true, "paytm": true},
+		"payout_gateway":  {"razorpayx": true, "paytm": true, "manual": true},
+	}
+	driversMu.RLock()
+	for _, sel := range []struct {
+		field, name string
+		registered  bool
+	}{
+		{"payment_gateway", c.PaymentGateway, paymentDrivers[c.PaymentGateway] != nil},
+		{"payout_gateway", c.PayoutGateway, payoutDrivers[c.PayoutGateway] != nil},
+	} {
+		switch {
+		case sel.name == "":
+		case builtin[sel.field][sel.name]:
+			if !configured[sel.name] {
+				errs = append(errs, fmt.Errorf("%s: %q is selected but has no credentials", sel.field, sel.name))
+			}
+		case !sel.registered:
+			errs = append(errs, fmt.Errorf("%s: unknown gateway %q", sel.field, sel.name))
+		}
+	}
+	driversMu.RUnlock()
+
+	if c.Production {
+		if strings.HasPrefix(rzp.KeyID, "rzp_test_") {
+			errs = append(errs, fmt.Errorf("razorpay: key_id is a test-mode key but production is set"))
+		}
+		if strings.HasPrefix(rzpx.KeyID, "rzp_test_") {
+			errs = append(errs, fmt.Errorf("razorpayx: key_id is a test-mode key but production is set"))
+		}
+		if configured["paytm"] && !paytm.Production {
+			errs = append(errs, fmt.Errorf("paytm: production is false but the config is marked production"))
+		}
+		if strings.EqualFold(paytm.Website, "WEBSTAGING") {
+			errs = append(errs, fmt.Errorf("paytm: website is WEBSTAGING but production is set"))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("pg: invalid config: %w", err)
+	}
+	return nil
+}
+
+// missingFields returns the sorted names of the empty fields.
+func missingFields(fields map[string]string) []string {
+	var out []string
+	for _, name := range sortedKeys(fields) {
+		if fields[name] == "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,198 @@
+package pg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// problems returns the individual messages of a Validate error.
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	msg, ok := strings.CutPrefix(err.Error(), "pg: invalid config: ")
+	if !ok {
+		t.Fatalf("error %q lacks the invalid config prefix", err)
+	}
+	return strings.Split(msg, "\n")
+}
+
+func TestConfigValidate(t *testing.T) {
+	secrets := SecretProviderFunc(func(context.Context, string) (string, error) { return "s", nil })
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"empty", Config{}, nil},
+		{
+			name: "complete",
+			cfg: Config{
+				PaymentGateway: "razorpay", PayoutGateway: "razorpayx",
+				Razorpay:   RazorpayConfig{KeyID: "rzp_live_1", KeySecret: "s"},
+				RazorpayX:  RazorpayXConfig{KeyID: "rzp_live_2", KeySecret: "s", AccountNumber: "acc"},
+				Paytm:      PaytmConfig{MID: "mid", MerchantKey: "key", Production: true},
+				Production: true,
+			},
+		},
+		{
+			name: "every missing field",
+			cfg: Config{
+				Razorpay:  RazorpayConfig{WebhookSecret: "w"},
+				RazorpayX: RazorpayXConfig{KeyID: "k"},
+				Paytm:     PaytmConfig{Website: "DEFAULT"},
+			},
+			want: []string{
+				"razorpay: missing key_id, key_secret",
+				"razorpayx: missing account_number, key_secret",
+				"paytm: missing merchant_key, mid",
+			},
+		},
+		{
+			name: "webhook secrets",
+			cfg: Config{
+				Razorpay: RazorpayConfig{KeyID: "k", KeySecret: "s", WebhookSecrets: []WebhookSecret{{ID: "a", Value: "v"}, {}}},
+				Paytm:    PaytmConfig{MID: "m", MerchantKey: "k", WebhookSecrets: []WebhookSecret{{Value: "v"}}},
+			},
+			want: []string{
+				"razorpay: webhook_secrets[1]: missing id, value",
+				"paytm: webhook_secrets[0]: missing id",
+			},
+		},
+		{
+			name: "secrets from a section provider",
+			cfg:  Config{Razorpay: RazorpayConfig{KeyID: "k", Secrets: secrets}},
+		},
+		{
+			name: "secrets from the shared provider",
+			cfg:  Config{Secrets: secrets, RazorpayX: RazorpayXConfig{KeyID: "k", AccountNumber: "acc"}, Paytm: PaytmConfig{MID: "m"}},
+		},
+		{
+			name: "selected gateways without credentials",
+			cfg:  Config{PaymentGateway: "paytm", PayoutGateway: "razorpayx"},
+			want: []string{
+				`payment_gateway: "paytm" is selected but has no credentials`,
+				`payout_gateway: "razorpayx" is selected but has no credentials`,
+			},
+		},
+		{
+			name: "unknown and registered gateways",
+			cfg:  Config{PaymentGateway: "stripe", PayoutGateway: "stub"},
+			want: []string{`payment_gateway: unknown gateway "stripe"`},
+		},
+		{
+			name: "manual payouts need no credentials",
+			cfg:  Config{PayoutGateway: "manual"},
+		},
+		{
+			name: "test credentials in production",
+			cfg: Config{
+				Production: true,
+				Razorpay:   RazorpayConfig{KeyID: "rzp_test_1", KeySecret: "s"},
+				RazorpayX:  RazorpayXConfig{KeyID: "rzp_test_2", KeySecret: "s", AccountNumber: "acc"},
+				Paytm:      PaytmConfig{MID: "m", MerchantKey: "k", Website: "WEBSTAGING"},
+			},
+			want: []string{
+				"razorpay: key_id is a test-mode key but production is set",
+				"razorpayx: key_id is a test-mode key but production is set",
+				"paytm: production is false but the config is marked production",
+				"paytm: website is WEBSTAGING but production is set",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := problems(t, tt.cfg.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Validate() problems = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOmitsValues(t *testing.T) {
+	cfg := Config{Production: true, Razorpay: RazorpayConfig{KeyID: "rzp_test_visible"}, Paytm: PaytmConfig{MerchantKey: "hidden_key"}}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil")
+	}
+	for _, value := range []string{"rzp_test_visible", "hidden_key"} {
+		if strings.Contains(err.Error(), value) {
+			t.Fatalf("Validate() error %q contains the value %q", err, value)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	want := &Config{PaymentGateway: "razorpay", Razorpay: RazorpayConfig{KeyID: "k", KeySecret: "s"}}
+	tests := []struct {
+		name    string
+		parse   func([]byte) (*Config, error)
+		data    string
+		wantErr string
+	}{
+		{"json", ParseConfigJSON, `{"payment_gateway":"razorpay","razorpay":{"key_id":"k","key_secret":"s"}}`, ""},
+		{"json unknown key", ParseConfigJSON, `{"payment_gateway":"razorpay","razorpay":{"keyid":"k"}}`, "parse config"},
+		{"json invalid", ParseConfigJSON, `{"payment_gateway":"razorpay"}`, `"razorpay" is selected but has no credentials`},
+		{"yaml", ParseConfigYAML, "payment_gateway: razorpay\nrazorpay:\n  key_id: k\n  key_secret: s\n", ""},
+		{"yaml unknown key", ParseConfigYAML, "payment_gateway: razorpay\nrazorpay:\n  keyid: k\n", "parse config"},
+		{"yaml invalid", ParseConfigYAML, "razorpay:\n  key_id: k\n", "razorpay: missing key_secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.parse([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parse error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(cfg, want) {
+				t.Fatalf("parse = %+v, %v; want %+v", cfg, err, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pg.yaml")
+	if err := os.WriteFile(path, []byte("payout_gateway: manual\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := LoadConfigYAML(path); err != nil || cfg.PayoutGateway != "manual" {
+		t.Fatalf("LoadConfigYAML() = %+v, %v", cfg, err)
+	}
+	if _, err := LoadConfigJSON(filepath.Join(dir, "missing.json")); err == nil || !strings.Contains(err.Error(), "pg: load config") {
+		t.Fatalf("LoadConfigJSON(missing) error = %v", err)
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv("TEST_PG_PAYMENT_GATEWAY", "paytm")
+	t.Setenv("TEST_PG_PAYTM_MID", "mid")
+	t.Setenv("TEST_PG_PAYTM_MERCHANT_KEY", "key")
+	t.Setenv("TEST_PG_PAYTM_PRODUCTION", "true")
+	cfg, err := LoadConfigFromEnv("TEST_PG_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PaymentGateway != "paytm" || cfg.Paytm.MID != "mid" || cfg.Paytm.MerchantKey != "key" || !cfg.Paytm.Production {
+		t.Fatalf("LoadConfigFromEnv() = %+v", cfg)
+	}
+
+	t.Setenv("TEST_PG_PRODUCTION", "maybe")
+	if _, err := LoadConfigFromEnv("TEST_PG_"); err == nil || !strings.Contains(err.Error(), "TEST_PG_PRODUCTION: not a boolean") {
+		t.Fatalf("LoadConfigFromEnv() error = %v, want a boolean error", err)
+	}
+
+	t.Setenv("TEST_PG_PRODUCTION", "")
+	t.Setenv("TEST_PG_PAYTM_MERCHANT_KEY", "")
+	if _, err := LoadConfigFromEnv("TEST_PG_"); err == nil || !strings.Contains(err.Error(), "paytm: missing merchant_key") {
+		t.Fatalf("LoadConfigFromEnv() error = %v, want a validation error", err)
+	}
+}
@@ -0,0 +1,172 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNotConfigured is returned by a driver when Config has no credentials for
+// its gateway. Helpers that build every gateway skip such drivers.
+var ErrNotConfigured = errors.New("pg: gateway not configured")
+
+// PaymentDriver builds a PaymentGateway from the shared Config. It returns an
+// error wrapping ErrNotConfigured when cfg has no credentials for it.
+type PaymentDriver func(cfg Config) (PaymentGateway, error)
+
+// PayoutDriver builds a PayoutGateway from the shared Config. It returns an
+// error wrapping ErrNotConfigured when cfg has no credentials for it.
+type PayoutDriver func(cfg Config) (PayoutGateway, error)
+
+var (
+	driversMu      sync.RWMutex
+	paymentDrivers = make(map[string]PaymentDriver)
+	payoutDrivers  = make(map[string]PayoutDriver)
+)
+
+// RegisterPaymentDriver makes a payment gateway available by name to
+// NewPayment and the FromConfig constructors. Adapter packages call it from
+// init, so importing an adapter (e.g. `import _ ".../razorpay"`) registers it.
+// It panics if name is registered twice or driver is nil.
+func RegisterPaymentDriver(name string, driver PaymentDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("pg: RegisterPaymentDriver driver is nil")
+	}
+	if _, dup := paymentDrivers[name]; dup {
+		panic("pg: RegisterPaymentDriver called twice for " + name)
+	}
+	paymentDrivers[name] = driver
+}
+
+// RegisterPayoutDriver makes a payout gateway available by name to NewPayout
+// and the FromConfig constructors. It panics if name is registered twice or
+// driver is nil.
+func RegisterPayoutDriver(name string, driver PayoutDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("pg: RegisterPayoutDriver driver is nil")
+	}
+	if _, dup := payoutDrivers[name]; dup {
+		panic("pg: RegisterPayoutDriver called twice for " + name)
+	}
+	payoutDrivers[name] = driver
+}
+
+// PaymentDrivers returns the sorted names of the registered payment drivers.
+func PaymentDrivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	return sortedKeys(paymentDrivers)
+}
+
+// PayoutDrivers returns the sorted names of the registered payout drivers.
+func PayoutDrivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	return sortedKeys(payoutDrivers)
+}
+
+// NewPayment builds the payment gateway selected by cfg.PaymentGateway.
+func NewPayment(cfg Config) (PaymentGateway, error) {
+	return newFromDriver("payment", cfg.PaymentGateway, paymentDrivers, cfg)
+}
+
+// NewPayout builds the payout gateway selected by cfg.PayoutGateway.
+func NewPayout(cfg Config) (PayoutGateway, error) {
+	return newFromDriver("payout", cfg.PayoutGateway, payoutDrivers, cfg)
+}
+
+func newFromDriver[T any, D ~func(Config) (T, error)](kind, name string, drivers map[string]D, cfg Config) (T, error) {
+	var zero T
+	if name == "" {
+		return zero, fmt.Errorf("pg: no %s gateway selected in config", kind)
+	}
+	driversMu.RLock()
+	driver, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return zero, fmt.Errorf("pg: unknown %s gateway %q (forgotten import?)", kind, name)
+	}
+	gw, err := driver(cfg)
+	if err != nil {
+		return zero, fmt.Errorf("pg: %s gateway %q: %w", kind, name, err)
+	}
+	return gw, nil
+}
+
+// NewPaymentGateways builds every registered payment gateway that cfg has
+// credentials for, keyed by driver name.
+func NewPaymentGateways(cfg Config) (map[string]PaymentGateway, error) {
+	return newAllFromDrivers("payment", paymentDrivers, cfg)
+}
+
+// NewPayoutGateways builds every registered payout gateway that cfg has
+// credentials for, keyed by driver name.
+func NewPayoutGateways(cfg Config) (map[string]PayoutGateway, error) {
+	return newAllFromDrivers("payout", payoutDrivers, cfg)
+}
+
+func newAllFromDrivers[T any, D ~func(Config) (T, error)](kind string, drivers map[string]D, cfg Config) (map[string]T, error) {
+	driversMu.RLock()
+	names := sortedKeys(drivers)
+	driversMu.RUnlock()
+
+	out := make(map[string]T, len(names))
+	for _, name := range names {
+		gw, err := newFromDriver(kind, name, drivers, cfg)
+		if errors.Is(err, ErrNotConfigured) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[name] = gw
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("pg: no %s gateway configured", kind)
+	}
+	return out, nil
+}
+
+// StaticResolver returns a GatewayResolver that always picks name.
+func StaticResolver(name string) GatewayResolver {
+	return func(context.Context) (string, error) { return name, nil }
+}
+
+// NewDynamicPaymentSwitcherFromConfig builds a DynamicPaymentSwitcher over
+// every payment gateway cfg has credentials for. A nil resolver always picks
+// cfg.PaymentGateway, which must then be one of the configured gateways.
+func NewDynamicPaymentSwitcherFromConfig(cfg Config, resolver GatewayResolver, opts ...SwitcherOption) (*DynamicPaymentSwitcher, error) {
+	gateways, err := NewPaymentGateways(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if resolver == nil {
+		if _, ok := gateways[cfg.PaymentGateway]; !ok {
+			return nil, fmt.Errorf("pg: payment gateway %q is not configured", cfg.PaymentGateway)
+		}
+		resolver = StaticResolver(cfg.PaymentGateway)
+	}
+	return NewDynamicPaymentSwitcher(gateways, resolver, opts...), nil
+}
+
+// NewDynamicPayoutSwitcherFromConfig builds a DynamicPayoutSwitcher over every
+// payout gateway cfg has credentials for. A nil resolver always picks
+// cfg.PayoutGateway, which must then be one of the configured gateways.
+func NewDynamicPayoutSwitcherFromConfig(cfg Config, resolver GatewayResolver, opts ...SwitcherOption) (*DynamicPayoutSwitcher, error) {
+	gateways, err := NewPayoutGateways(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if resolver == nil {
+		if _, ok := gateways[cfg.PayoutGateway]; !ok {
+			return nil, fmt.Errorf("pg: payout gateway %q is not configured", cfg.PayoutGateway)
+		}
+		resolver = StaticResolver(cfg.PayoutGateway)
+	}
+	return NewDynamicPayoutSwitcher(gateways, resolver, opts...), nil
+}
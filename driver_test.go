@@ -0,0 +1,170 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var errBadKey = errors.New("bad key")
+
+// payoutStub is built by the "stub" payout driver. Only Name is implemented.
+type payoutStub struct {
+	PayoutGateway
+	name string
+}
+
+func (g *payoutStub) Name() string { return g.name }
+
+// The "stub" drivers are configured by the Razorpay and RazorpayX sections; a
+// key ID of "bad" makes the payment driver fail.
+func init() {
+	RegisterPaymentDriver("stub", func(cfg Config) (PaymentGateway, error) {
+		switch cfg.Razorpay.KeyID {
+		case "":
+			return nil, ErrNotConfigured
+		case "bad":
+			return nil, errBadKey
+		}
+		return &stubGateway{name: "stub"}, nil
+	})
+	RegisterPayoutDriver("stub", func(cfg Config) (PayoutGateway, error) {
+		if cfg.RazorpayX.AccountNumber == "" {
+			return nil, ErrNotConfigured
+		}
+		return &payoutStub{name: "stub"}, nil
+	})
+}
+
+func TestNewPayment(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr string
+		wantIs  error
+	}{
+		{"selected driver", Config{PaymentGateway: "stub", Razorpay: RazorpayConfig{KeyID: "k"}}, "stub", "", nil},
+		{"no selection", Config{Razorpay: RazorpayConfig{KeyID: "k"}}, "", "no payment gateway selected", nil},
+		{"unknown driver", Config{PaymentGateway: "nope"}, "", `unknown payment gateway "nope"`, nil},
+		{"not configured", Config{PaymentGateway: "stub"}, "", `payment gateway "stub"`, ErrNotConfigured},
+		{"driver error", Config{PaymentGateway: "stub", Razorpay: RazorpayConfig{KeyID: "bad"}}, "", `payment gateway "stub"`, errBadKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, err := NewPayment(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil || gw.Name() != tt.want {
+					t.Fatalf("NewPayment() = %v, %v; want %s", gw, err, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Fatalf("NewPayment() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPayout(t *testing.T) {
+	gw, err := NewPayout(Config{PayoutGateway: "stub", RazorpayX: RazorpayXConfig{AccountNumber: "acc"}})
+	if err != nil || gw.Name() != "stub" {
+		t.Fatalf("NewPayout() = %v, %v; want stub", gw, err)
+	}
+	if _, err := NewPayout(Config{PayoutGateway: "stub"}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("NewPayout() error = %v, want ErrNotConfigured", err)
+	}
+	if _, err := NewPayout(Config{}); err == nil {
+		t.Fatal("NewPayout() with no selection succeeded")
+	}
+}
+
+func TestNewPaymentGateways(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    []string
+		wantErr error
+	}{
+		{"skips unconfigured drivers", Config{Razorpay: RazorpayConfig{KeyID: "k"}}, []string{"stub"}, nil},
+		{"builds every configured driver", Config{Razorpay: RazorpayConfig{KeyID: "k"}, Paytm: PaytmConfig{MID: "m"}}, []string{"fake", "stub"}, nil},
+		{"nothing configured", Config{}, nil, nil},
+		{"driver error", Config{Razorpay: RazorpayConfig{KeyID: "bad"}, Paytm: PaytmConfig{MID: "m"}}, nil, errBadKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateways, err := NewPaymentGateways(tt.cfg)
+			if tt.want == nil {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NewPaymentGateways() = %v, %v; want error %v", gateways, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(gateways) != len(tt.want) {
+				t.Fatalf("NewPaymentGateways() = %v, %v; want %v", gateways, err, tt.want)
+			}
+			for _, name := range tt.want {
+				if gateways[name] == nil {
+					t.Fatalf("NewPaymentGateways() = %v, missing %s", gateways, name)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDynamicSwitchersFromConfig(t *testing.T) {
+	cfg := Config{PaymentGateway: "stub", PayoutGateway: "stub",
+		Razorpay: RazorpayConfig{KeyID: "k"}, RazorpayX: RazorpayXConfig{AccountNumber: "acc"}, Paytm: PaytmConfig{MID: "m"}}
+
+	s, err := NewDynamicPaymentSwitcherFromConfig(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := s.GatewayNames(); len(names) != 2 {
+		t.Fatalf("GatewayNames() = %v, want fake and stub", names)
+	}
+	order, err := s.CreateOrder(context.Background(), CreateOrderRequest{Amount: 100, Currency: "INR"})
+	if err != nil || order.Gateway != "stub" {
+		t.Fatalf("CreateOrder = %+v, %v; want an order on the selected gateway", order, err)
+	}
+	if _, err := NewDynamicPaymentSwitcherFromConfig(cfg, StaticResolver("fake")); err != nil {
+		t.Fatalf("with a resolver: %v", err)
+	}
+
+	unselected := cfg
+	unselected.Razorpay = RazorpayConfig{}
+	if _, err := NewDynamicPaymentSwitcherFromConfig(unselected, nil); err == nil || !strings.Contains(err.Error(), `"stub" is not configured`) {
+		t.Fatalf("selected gateway without credentials: error = %v", err)
+	}
+
+	p, err := NewDynamicPayoutSwitcherFromConfig(cfg, nil)
+	if err != nil || len(p.GatewayNames()) != 1 {
+		t.Fatalf("NewDynamicPayoutSwitcherFromConfig() = %v, %v", p, err)
+	}
+	if _, err := NewDynamicPayoutSwitcherFromConfig(Config{PayoutGateway: "stub"}, nil); err == nil {
+		t.Fatal("payout switcher with nothing configured succeeded")
+	}
+}
+
+func TestRegisterDriverPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"duplicate payment", func() { RegisterPaymentDriver("stub", func(Config) (PaymentGateway, error) { return nil, nil }) }},
+		{"nil payment", func() { RegisterPaymentDriver("nil", nil) }},
+		{"duplicate payout", func() { RegisterPayoutDriver("stub", func(Config) (PayoutGateway, error) { return nil, nil }) }},
+		{"nil payout", func() { RegisterPayoutDriver("nil", nil) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("did not panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
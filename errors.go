@@ -0,0 +1,144 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var (
+	// ErrUnauthenticated means the gateway rejected the configured credentials
+	ErrUnauthenticated = errors.New("pg: gateway rejected credentials")
+
+	// ErrGatewayUnavailable means the gateway could not be reached or failed on its side
+	ErrGatewayUnavailable = errors.New("pg: gateway unavailable")
+
+	// ErrInvalidRequest means the gateway rejected the request itself, e.g. a
+	// bad amount or a duplicate order ID; retrying it unchanged will not help
+	ErrInvalidRequest = errors.New("pg: invalid request")
+
+	// ErrNotFound means the order, payment, refund or payout does not exist
+	ErrNotFound = errors.New("pg: not found")
+
+	// ErrInsufficientBalance means the merchant or payout account cannot cover
+	// the refund or payout
+	ErrInsufficientBalance = errors.New("pg: insufficient balance")
+
+	// ErrRateLimited means the gateway throttled the caller
+	ErrRateLimited = errors.New("pg: rate limited")
+
+	// ErrNotImplemented means the adapter does not support the operation
+	ErrNotImplemented = errors.New("pg: not implemented")
+)
+
+// GatewayError is a failure reported by a gateway, classified onto one of the
+// sentinels above. errors.Is matches it against its Kind and anything Err
+// wraps; use errors.As to read the raw gateway code:
+//
+//	var gwErr *pg.GatewayError
+//	if errors.As(err, &gwErr) && gwErr.Code == "325" { ... }
+type GatewayError struct {
+	Gateway   string // adapter name, e.g. "razorpay"
+	Kind      error  // ErrInvalidRequest, ErrNotFound, ...; nil when unclassified
+	Code      string // raw gateway code, e.g. a Paytm resultCode or "BAD_REQUEST_ERROR"
+	Message   string // gateway's description of the failure
+	Retriable bool   // whether the call may succeed if retried
+	Err       error  // underlying error, e.g. from the gateway SDK; may be nil
+}
+
+func (e *GatewayError) Error() string {
+	msg := e.Message
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Kind != nil {
+		msg = e.Kind.Error() + ": " + msg
+	}
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s (code %s)", msg, e.Code)
+	}
+	return msg
+}
+
+// Unwrap returns Kind and Err, so errors.Is and errors.As see both.
+func (e *GatewayError) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// retriableError marks an error as a transient gateway failure.
+type retriableError struct{ err error }
+
+func (e *retriableError) Error() string   { return e.err.Error() }
+func (e *retriableError) Unwrap() error   { return e.err }
+func (e *retriableError) Retriable() bool { return true }
+
+// MarkRetriable wraps err so that IsRetriable reports true for it. Adapters use
+// it for timeouts, 5xx responses and gateway-side errors. It returns nil for a
+// nil err.
+func MarkRetriable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retriableError{err: err}
+}
+
+// IsRetriable reports whether err is a transient failure that is safe to retry
+// or to fail over to another gateway. Errors marked by the adapter, a
+// GatewayError with Retriable set and network errors (timeouts, refused
+// connections) are retriable; everything else, including cancellation of the
+// caller's context, is not.
+func IsRetriable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var r interface{ Retriable() bool }
+	if errors.As(err, &r) {
+		return r.Retriable()
+	}
+	var gwErr *GatewayError
+	if errors.As(err, &gwErr) {
+		return gwErr.Retriable
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// ErrorClass returns a short, low-cardinality label for err, suitable for
+// span attributes and metric labels. It returns "" for a nil error.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrUnauthenticated):
+		return "unauthenticated"
+	case errors.Is(err, ErrGatewayUnavailable):
+		return "gateway_unavailable"
+	case errors.Is(err, ErrInvalidRequest):
+		return "invalid_request"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrInsufficientBalance):
+		return "insufficient_balance"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrNotImplemented):
+		return "not_implemented"
+	case IsRetriable(err):
+		return "retriable"
+	}
+	return "error"
+}
@@ -6,29 +6,39 @@ import "context"
 type WebhookEventType string
 
 const (
-	WebhookEventPaymentSuccess  WebhookEventType = "payment.success"
-	WebhookEventPaymentFailed   WebhookEventType = "payment.failed"
-	WebhookEventOrderPaid       WebhookEventType = "order.paid"
-	WebhookEventRefundSuccess   WebhookEventType = "refund.success"
-	WebhookEventRefundFailed    WebhookEventType = "refund.failed"
-	WebhookEventDisputeCreated  WebhookEventType = "dispute.created"
-	WebhookEventDisputeWon      WebhookEventType = "dispute.won"
-	WebhookEventDisputeLost     WebhookEventType = "dispute.lost"
-	WebhookEventDisputeClosed   WebhookEventType = "dispute.closed"
-	WebhookEventUnknown         WebhookEventType = "unknown"
+	WebhookEventPaymentAuthorized WebhookEventType = "payment.authorized"
+	WebhookEventPaymentSuccess    WebhookEventType = "payment.success"
+	WebhookEventPaymentFailed     WebhookEventType = "payment.failed"
+	WebhookEventOrderPaid         WebhookEventType = "order.paid"
+	WebhookEventRefundSuccess     WebhookEventType = "refund.success"
+	WebhookEventRefundFailed      WebhookEventType = "refund.failed"
+	WebhookEventDisputeCreated    WebhookEventType = "dispute.created"
+	WebhookEventDisputeWon        WebhookEventType = "dispute.won"
+	WebhookEventDisputeLost       WebhookEventType = "dispute.lost"
+	WebhookEventDisputeClosed     WebhookEventType = "dispute.closed"
+	WebhookEventUnknown           WebhookEventType = "unknown"
 )
 
 // CreateOrderRequest contains fields for creating a payment order
 type CreateOrderRequest struct {
-	Amount      int64             // in smallest currency unit (paise)
-	Currency    string            // e.g. "INR"
-	Receipt     string            // booking ref or similar
-	Notes       map[string]string // arbitrary key-value notes
+	Amount   int64             // in smallest currency unit (paise)
+	Currency string            // e.g. "INR"
+	Receipt  string            // booking ref or similar
+	Notes    map[string]string // arbitrary key-value notes
+
+	// AuthorizeOnly holds the funds without capturing them; the payment is
+	// later captured or released through PaymentCapturer. Adapters that
+	// cannot do this fail with ErrNotImplemented.
+	AuthorizeOnly bool
 }
 
+// Money returns Amount and Currency as Money.
+func (r CreateOrderRequest) Money() Money { return Money{Amount: r.Amount, Currency: r.Currency} }
+
 // CreateOrderResponse is returned after successfully creating an order
 type CreateOrderResponse struct {
-	GatewayOrderID string            // gateway-specific order/transaction ID
+	GatewayOrderID string // gateway-specific order/transaction ID
+	Gateway        string // name of the gateway that created the order
 	Amount         int64
 	Currency       string
 	Notes          map[string]string
@@ -36,6 +46,9 @@ type CreateOrderResponse struct {
 	Extra map[string]interface{}
 }
 
+// Money returns Amount and Currency as Money.
+func (r CreateOrderResponse) Money() Money { return Money{Amount: r.Amount, Currency: r.Currency} }
+
 // VerifyPaymentRequest contains fields for verifying a payment
 type VerifyPaymentRequest struct {
 	GatewayOrderID   string
@@ -43,31 +56,59 @@ type VerifyPaymentRequest struct {
 	Signature        string
 }
 
+// PaymentState is the gateway-agnostic state of an order's payment
+type PaymentState string
+
+const (
+	PaymentStateCreated           PaymentState = "created"            // order created, no payment attempted yet
+	PaymentStatePending           PaymentState = "pending"            // payment attempted, outcome not yet known
+	PaymentStateAuthorized        PaymentState = "authorized"         // funds held, not yet captured
+	PaymentStateCaptured          PaymentState = "captured"           // funds captured
+	PaymentStateFailed            PaymentState = "failed"             // payment failed or was declined
+	PaymentStatePartiallyRefunded PaymentState = "partially_refunded" // captured, part of the amount refunded
+	PaymentStateRefunded          PaymentState = "refunded"           // captured, full amount refunded
+	PaymentStateDisputed          PaymentState = "disputed"           // captured, charged back or under dispute
+	PaymentStateUnknown           PaymentState = "unknown"            // gateway status not recognised
+)
+
 // PaymentStatus represents the current status of a payment from the gateway
 type PaymentStatus struct {
 	GatewayOrderID   string
 	GatewayPaymentID string
-	Status           string // gateway-specific status string
+	State            PaymentState // normalised across gateways
+	Status           string       // gateway-specific status string, e.g. "paid" or "TXN_SUCCESS"
 	Paid             bool
 }
 
 // RefundRequest contains fields for initiating a refund
 type RefundRequest struct {
 	GatewayPaymentID string
-	Amount           int64
+	GatewayOrderID   string // required by Paytm, which refunds by order
+	RefundID         string // caller-chosen unique refund ID, e.g. Paytm's refId or Razorpay's receipt
+	Amount           int64  // in the currency's minor unit; 0 refunds the remaining amount
+	Currency         string // currency of the payment; checked by adapters when set
 	Notes            map[string]string
+	IdempotencyKey   string // deduplicates refunds through NewIdempotentPaymentGateway
 }
 
+// Money returns Amount and Currency as Money.
+func (r RefundRequest) Money() Money { return Money{Amount: r.Amount, Currency: r.Currency} }
+
 // RefundResponse is returned after initiating a refund
 type RefundResponse struct {
 	RefundID string
 	Amount   int64
+	Currency string
 	Status   string
 }
 
+// Money returns Amount and Currency as Money.
+func (r RefundResponse) Money() Money { return Money{Amount: r.Amount, Currency: r.Currency} }
+
 // WebhookEvent is a normalised representation of a payment gateway webhook event
 type WebhookEvent struct {
 	Type             WebhookEventType
+	State            PaymentState // state the event moves the payment to; empty for refund events
 	GatewayOrderID   string
 	GatewayPaymentID string
 	RefundID         string
@@ -79,6 +120,17 @@ type WebhookEvent struct {
 	Raw map[string]interface{}
 }
 
+// Money returns Amount and Currency as Money.
+func (e WebhookEvent) Money() Money { return Money{Amount: e.Amount, Currency: e.Currency} }
+
+// VerifiedWebhook is a webhook event together with the gateway that verified it
+type VerifiedWebhook struct {
+	Gateway  string // registered name of the gateway whose signature check passed
+	SecretID string // ID of the webhook secret that matched, if the adapter reports it
+	TenantID string // tenant whose secret matched, set by the tenant switchers
+	Event    *WebhookEvent
+}
+
 // PaymentGateway is the common interface that all payment gateway adapters implement
 type PaymentGateway interface {
 	// Name returns the unique gateway identifier (e.g. "razorpay", "paytm")
@@ -2,4 +2,7 @@ module github.com/KriaaCompany/pg-switcher-sdk
 
 go 1.21
 
-require github.com/razorpay/razorpay-go v1.4.0
+require (
+	github.com/razorpay/razorpay-go v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
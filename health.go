@@ -0,0 +1,47 @@
+package pg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HealthChecker is implemented by adapters that can check their credentials
+// and connectivity with a cheap authenticated call.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) HealthCheckResult
+}
+
+// HealthCheckResult reports the outcome of a health check. Err wraps
+// ErrUnauthenticated when the credentials were rejected and
+// ErrGatewayUnavailable when the gateway could not be reached.
+type HealthCheckResult struct {
+	Gateway string
+	Healthy bool
+	Skipped bool // the adapter does not implement HealthChecker
+	Latency time.Duration
+	Err     error
+}
+
+// checkAll runs HealthCheck on every gateway concurrently. Results carry the
+// registered name and are returned in the order of names.
+func checkAll[T any](ctx context.Context, names []string, gateways map[string]T) []HealthCheckResult {
+	results := make([]HealthCheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		hc, ok := any(gateways[name]).(HealthChecker)
+		if !ok {
+			results[i] = HealthCheckResult{Gateway: name, Healthy: true, Skipped: true}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, name string, hc HealthChecker) {
+			defer wg.Done()
+			res := hc.HealthCheck(ctx)
+			res.Gateway = name
+			results[i] = res
+		}(i, name, hc)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,303 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrIdempotencyConflict is returned when an idempotency key is reused with a
+// different request, e.g. the same receipt for a different amount.
+var ErrIdempotencyConflict = errors.New("pg: idempotency key reused with a different request")
+
+// IdempotencyStore keeps the responses of completed calls by idempotency key.
+//
+// Implementations must be safe for concurrent use. A shared store (e.g.
+// Redis, with SET NX for Lock) is needed to deduplicate across instances.
+type IdempotencyStore interface {
+	// Lock blocks until the caller holds key or ctx is done. The returned
+	// function releases the key and must be called.
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+
+	// Get returns the record stored under key, and false if there is none or
+	// it has expired.
+	Get(ctx context.Context, key string) (record []byte, found bool, err error)
+
+	// Put stores record under key for ttl.
+	Put(ctx context.Context, key string, record []byte, ttl time.Duration) error
+}
+
+type idempotencyEntry struct {
+	record  []byte
+	expires time.Time
+}
+
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore. Records are lost
+// on restart and are not shared between instances.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]idempotencyEntry
+	locks     map[string]*keyLock
+	lastSweep time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries:   make(map[string]idempotencyEntry),
+		locks:     make(map[string]*keyLock),
+		lastSweep: time.Now(),
+	}
+}
+
+// Lock implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Lock(ctx context.Context, key string) (func(), error) {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			m.release(key, l)
+		}, nil
+	case <-ctx.Done():
+		m.release(key, l)
+		return nil, ctx.Err()
+	}
+}
+
+func (m *MemoryIdempotencyStore) release(key string, l *keyLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l.refs--; l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+// Get implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false, nil
+	}
+	return e.record, true, nil
+}
+
+// Put implements IdempotencyStore. Expired records are swept at most once a
+// minute.
+func (m *MemoryIdempotencyStore) Put(_ context.Context, key string, record []byte, ttl time.Duration) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = idempotencyEntry{record: record, expires: now.Add(ttl)}
+	if now.Sub(m.lastSweep) >= time.Minute {
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	return nil
+}
+
+// idempotencyRecord is what gets stored: a fingerprint of the request and the
+// JSON-encoded response.
+type idempotencyRecord struct {
+	Request  string          `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// NewIdempotentPaymentGateway returns a PaymentGateway that deduplicates
+// CreateOrder by Receipt and InitiateRefund by IdempotencyKey. A call whose
+// key was seen within ttl returns the stored response without reaching gw;
+// concurrent calls with the same key run one at a time. Calls with an empty
+// key, and all other methods, go straight to gw. Failed calls are not
+// stored, so they can be retried.
+//
+// gw may be a DynamicPaymentSwitcher, in which case a replayed order keeps the
+// gateway that originally created it.
+//
+// Keys are scoped by the tenant in ctx (see WithTenant and WithKeyScope), so
+// one store can serve every tenant of a TenantPaymentSwitcher.
+//
+// If the store fails to save the response of a successful call, the response
+// is still returned, since the order or refund exists; the failure goes to the
+// observer set with WithStoreErrorObserver.
+func NewIdempotentPaymentGateway(gw PaymentGateway, store IdempotencyStore, ttl time.Duration, opts ...IdempotencyOption) PaymentGateway {
+	g := &idempotentPaymentGateway{PaymentGateway: gw, store: store, ttl: ttl}
+	for _, opt := range opts {
+		opt(&g.idempotencyOptions)
+	}
+	return g
+}
+
+// IdempotencyOption configures NewIdempotentPaymentGateway.
+type IdempotencyOption func(*idempotencyOptions)
+
+type idempotencyOptions struct {
+	onStoreError func(ctx context.Context, key string, err error)
+	scope        func(ctx context.Context) string
+}
+
+// WithStoreErrorObserver registers fn to be called when the response of a
+// successful call could not be stored, e.g. to log it or count it in a
+// metric. A repeated call with the same key will then reach the gateway again.
+func WithStoreErrorObserver(fn func(ctx context.Context, key string, err error)) IdempotencyOption {
+	return func(o *idempotencyOptions) { o.onStoreError = fn }
+}
+
+// WithKeyScope sets the namespace that idempotency keys are stored under, so
+// that callers sharing a store cannot replay each other's responses. The
+// default is the tenant set with WithTenant; use this option when a
+// TenantGatewayResolver determines the tenant some other way, or to add the
+// gateway or merchant account. An empty scope leaves the key as it is.
+func WithKeyScope(fn func(ctx context.Context) string) IdempotencyOption {
+	return func(o *idempotencyOptions) { o.scope = fn }
+}
+
+// storeKey returns the store key of an idempotency key of the given kind
+// ("order" or "refund"), prefixed with the escaped scope when there is one.
+func (o *idempotencyOptions) storeKey(ctx context.Context, kind, key string) string {
+	scope := TenantFromContext(ctx)
+	if o.scope != nil {
+		scope = o.scope(ctx)
+	}
+	if scope == "" {
+		return kind + ":" + key
+	}
+	return url.PathEscape(scope) + "/" + kind + ":" + key
+}
+
+type idempotentPaymentGateway struct {
+	PaymentGateway
+	idempotencyOptions
+	store IdempotencyStore
+	ttl   time.Duration
+}
+
+func (g *idempotentPaymentGateway) CreateOrder(ctx context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	if req.Receipt == "" {
+		return g.PaymentGateway.CreateOrder(ctx, req)
+	}
+	return idempotent(ctx, g.store, g.ttl, g.onStoreError, g.storeKey(ctx, "order", req.Receipt), req, g.PaymentGateway.CreateOrder)
+}
+
+func (g *idempotentPaymentGateway) InitiateRefund(ctx context.Context, req RefundRequest) (*RefundResponse, error) {
+	if req.IdempotencyKey == "" {
+		return g.PaymentGateway.InitiateRefund(ctx, req)
+	}
+	return idempotent(ctx, g.store, g.ttl, g.onStoreError, g.storeKey(ctx, "refund", req.IdempotencyKey), req, g.PaymentGateway.InitiateRefund)
+}
+
+// HealthCheck passes through to the wrapped gateway, reporting Skipped when
+// it has no health check.
+func (g *idempotentPaymentGateway) HealthCheck(ctx context.Context) HealthCheckResult {
+	res, _ := healthCheckFunc(g.PaymentGateway, g.Name())(ctx, nil)
+	return res
+}
+
+// GetRefundStatus and ListRefunds pass through to the wrapped gateway,
+// failing with ErrNotImplemented when it cannot look refunds up.
+func (g *idempotentPaymentGateway) GetRefundStatus(ctx context.Context, req RefundLookup) (*Refund, error) {
+	return getRefundStatusFunc(g.PaymentGateway, g.Name())(ctx, req)
+}
+
+func (g *idempotentPaymentGateway) ListRefunds(ctx context.Context, req RefundLookup) ([]Refund, error) {
+	return listRefundsFunc(g.PaymentGateway, g.Name())(ctx, req)
+}
+
+// CapturePayment and VoidPayment pass through to the wrapped gateway,
+// failing with ErrNotImplemented when it does not support manual capture.
+func (g *idempotentPaymentGateway) CapturePayment(ctx context.Context, req CaptureRequest) (*PaymentStatus, error) {
+	return capturePaymentFunc(g.PaymentGateway, g.Name())(ctx, req)
+}
+
+func (g *idempotentPaymentGateway) VoidPayment(ctx context.Context, req VoidRequest) (*PaymentStatus, error) {
+	return voidPaymentFunc(g.PaymentGateway, g.Name())(ctx, req)
+}
+
+func (g *idempotentPaymentGateway) supportsManualCapture() bool {
+	return supportsManualCapture(g.PaymentGateway)
+}
+
+// VerifyWebhook passes through to the wrapped gateway.
+func (g *idempotentPaymentGateway) VerifyWebhook(payload []byte, headers map[string]string) (string, bool) {
+	return verifyWebhookWithID(g.PaymentGateway, payload, headers)
+}
+
+// idempotent runs fn at most once per key within ttl, replaying the stored
+// response for repeated calls. A failure to store the response of a
+// successful call is reported to onStoreError, which may be nil, and does not
+// fail the call.
+func idempotent[Req, Resp any](ctx context.Context, store IdempotencyStore, ttl time.Duration, onStoreError func(context.Context, string, error), key string, req Req, fn func(context.Context, Req) (Resp, error)) (Resp, error) {
+	var zero Resp
+	fingerprint, err := json.Marshal(req)
+	if err != nil {
+		return zero, fmt.Errorf("pg: idempotency: encode request: %w", err)
+	}
+	sum := sha256.Sum256(fingerprint)
+	reqHash := hex.EncodeToString(sum[:])
+
+	unlock, err := store.Lock(ctx, key)
+	if err != nil {
+		return zero, fmt.Errorf("pg: idempotency: lock %q: %w", key, err)
+	}
+	defer unlock()
+
+	data, found, err := store.Get(ctx, key)
+	if err != nil {
+		return zero, fmt.Errorf("pg: idempotency: get %q: %w", key, err)
+	}
+	if found {
+		var rec idempotencyRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return zero, fmt.Errorf("pg: idempotency: decode record %q: %w", key, err)
+		}
+		if rec.Request != reqHash {
+			return zero, fmt.Errorf("%w: %q", ErrIdempotencyConflict, key)
+		}
+		var resp Resp
+		if err := json.Unmarshal(rec.Response, &resp); err != nil {
+			return zero, fmt.Errorf("pg: idempotency: decode response %q: %w", key, err)
+		}
+		return resp, nil
+	}
+
+	resp, err := fn(ctx, req)
+	if err != nil {
+		return zero, err
+	}
+	body, err := json.Marshal(resp)
+	if err == nil {
+		data, err = json.Marshal(idempotencyRecord{Request: reqHash, Response: body})
+	}
+	if err == nil {
+		// The call has happened; do not lose its record to the caller's cancellation
+		err = store.Put(context.WithoutCancel(ctx), key, data, ttl)
+	}
+	if err != nil && onStoreError != nil {
+		onStoreError(ctx, key, fmt.Errorf("pg: idempotency: store %q: %w", key, err))
+	}
+	return resp, nil
+}
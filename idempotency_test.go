@@ -0,0 +1,296 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// orderGateway creates orders with sequential IDs, failing while fail is set.
+type orderGateway struct {
+	PaymentGateway
+	calls atomic.Int32
+	fail  error
+	delay time.Duration
+}
+
+func (g *orderGateway) Name() string { return "test" }
+
+func (g *orderGateway) CreateOrder(_ context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	n := g.calls.Add(1)
+	time.Sleep(g.delay)
+	if g.fail != nil {
+		return nil, g.fail
+	}
+	return &CreateOrderResponse{
+		GatewayOrderID: fmt.Sprintf("order_%d", n),
+		Gateway:        "test",
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		Notes:          req.Notes,
+		Extra:          map[string]interface{}{"txn_token": "token"},
+	}, nil
+}
+
+func (g *orderGateway) InitiateRefund(_ context.Context, req RefundRequest) (*RefundResponse, error) {
+	n := g.calls.Add(1)
+	return &RefundResponse{RefundID: fmt.Sprintf("rfnd_%d", n), Amount: req.Amount, Currency: req.Currency, Status: "pending"}, nil
+}
+
+// failingPutStore is a MemoryIdempotencyStore whose Put always fails.
+type failingPutStore struct{ *MemoryIdempotencyStore }
+
+func (failingPutStore) Put(context.Context, string, []byte, time.Duration) error {
+	return errors.New("store down")
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	ctx := context.Background()
+	order := CreateOrderRequest{Amount: 10050, Currency: "INR", Receipt: "bk_1", Notes: map[string]string{"k": "v"}}
+
+	tests := []struct {
+		name      string
+		second    CreateOrderRequest
+		wantCalls int32
+		wantErr   error
+	}{
+		{"same request replays", order, 1, nil},
+		{"different request conflicts", CreateOrderRequest{Amount: 1, Currency: "INR", Receipt: "bk_1"}, 1, ErrIdempotencyConflict},
+		{"different key runs", CreateOrderRequest{Amount: 10050, Currency: "INR", Receipt: "bk_2"}, 2, nil},
+		{"empty key is not deduplicated", CreateOrderRequest{Amount: 10050, Currency: "INR"}, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &orderGateway{}
+			gw := NewIdempotentPaymentGateway(inner, NewMemoryIdempotencyStore(), time.Hour)
+
+			first, err := gw.CreateOrder(ctx, order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := gw.CreateOrder(ctx, tt.second)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("second call error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if tt.second.Receipt == order.Receipt && !reflect.DeepEqual(first, second) {
+				t.Fatalf("replayed response = %+v, want %+v", second, first)
+			}
+			if got := inner.calls.Load(); got != tt.wantCalls {
+				t.Fatalf("gateway called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotentRefundReplay(t *testing.T) {
+	ctx := context.Background()
+	inner := &orderGateway{}
+	gw := NewIdempotentPaymentGateway(inner, NewMemoryIdempotencyStore(), time.Hour)
+	req := RefundRequest{GatewayPaymentID: "pay_1", Amount: 500, Currency: "INR", IdempotencyKey: "rf_1"}
+
+	first, err := gw.InitiateRefund(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := gw.InitiateRefund(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *first != *second || inner.calls.Load() != 1 {
+		t.Fatalf("got %+v then %+v after %d calls, want one call replayed", first, second, inner.calls.Load())
+	}
+}
+
+func TestIdempotentFailureNotStored(t *testing.T) {
+	ctx := context.Background()
+	inner := &orderGateway{fail: ErrGatewayUnavailable}
+	gw := NewIdempotentPaymentGateway(inner, NewMemoryIdempotencyStore(), time.Hour)
+	req := CreateOrderRequest{Amount: 100, Currency: "INR", Receipt: "bk_1"}
+
+	if _, err := gw.CreateOrder(ctx, req); !errors.Is(err, ErrGatewayUnavailable) {
+		t.Fatalf("first call error = %v, want ErrGatewayUnavailable", err)
+	}
+	inner.fail = nil
+	resp, err := gw.CreateOrder(ctx, req)
+	if err != nil || resp.GatewayOrderID != "order_2" {
+		t.Fatalf("retry = %+v, %v; want order_2", resp, err)
+	}
+}
+
+func TestIdempotentExpiry(t *testing.T) {
+	ctx := context.Background()
+	inner := &orderGateway{}
+	gw := NewIdempotentPaymentGateway(inner, NewMemoryIdempotencyStore(), time.Nanosecond)
+	req := CreateOrderRequest{Amount: 100, Currency: "INR", Receipt: "bk_1"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := gw.CreateOrder(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := inner.calls.Load(); got != 2 {
+		t.Fatalf("gateway called %d times after the record expired, want 2", got)
+	}
+}
+
+func TestIdempotentConcurrent(t *testing.T) {
+	ctx := context.Background()
+	inner := &orderGateway{delay: 10 * time.Millisecond}
+	gw := NewIdempotentPaymentGateway(inner, NewMemoryIdempotencyStore(), time.Hour)
+	req := CreateOrderRequest{Amount: 100, Currency: "INR", Receipt: "bk_1"}
+
+	const n = 8
+	ids := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := gw.CreateOrder(ctx, req)
+			errs[i] = err
+			if err == nil {
+				ids[i] = resp.GatewayOrderID
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || ids[i] != "order_1" {
+			t.Fatalf("call %d = %q, %v; want order_1", i, ids[i], errs[i])
+		}
+	}
+	if got := inner.calls.Load(); got != 1 {
+		t.Fatalf("gateway called %d times, want 1", got)
+	}
+}
+
+func TestIdempotentLockHonoursContext(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	unlock, err := store.Lock(context.Background(), "order:bk_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	inner := &orderGateway{}
+	gw := NewIdempotentPaymentGateway(inner, store, time.Hour)
+	if _, err := gw.CreateOrder(ctx, CreateOrderRequest{Receipt: "bk_1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CreateOrder while locked = %v, want context.DeadlineExceeded", err)
+	}
+	if inner.calls.Load() != 0 {
+		t.Fatal("gateway called while the key was locked")
+	}
+}
+
+func TestIdempotentStoreError(t *testing.T) {
+	var observed []string
+	inner := &orderGateway{}
+	gw := NewIdempotentPaymentGateway(inner, failingPutStore{NewMemoryIdempotencyStore()}, time.Hour,
+		WithStoreErrorObserver(func(_ context.Context, key string, err error) {
+			if err == nil {
+				t.Error("observer called with a nil error")
+			}
+			observed = append(observed, key)
+		}))
+	req := CreateOrderRequest{Amount: 100, Currency: "INR", Receipt: "bk_1"}
+
+	resp, err := gw.CreateOrder(context.Background(), req)
+	if err != nil || resp == nil || resp.GatewayOrderID != "order_1" {
+		t.Fatalf("CreateOrder = %+v, %v; want the order despite the store error", resp, err)
+	}
+	if !reflect.DeepEqual(observed, []string{"order:bk_1"}) {
+		t.Fatalf("observer got %v, want [order:bk_1]", observed)
+	}
+}
+
+// recordingStore is a MemoryIdempotencyStore that records the keys it stores.
+type recordingStore struct {
+	*MemoryIdempotencyStore
+	mu   sync.Mutex
+	keys []string
+}
+
+func (s *recordingStore) Put(ctx context.Context, key string, record []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+	return s.MemoryIdempotencyStore.Put(ctx, key, record, ttl)
+}
+
+func TestIdempotentKeyScope(t *testing.T) {
+	merchant := func(ctx context.Context) string { m, _ := ctx.Value(merchantKey{}).(string); return m }
+	tests := []struct {
+		name    string
+		opts    []IdempotencyOption
+		ctx     func(i int) context.Context // context of the i-th of two calls with one receipt
+		want    []string
+		wantRun int32
+	}{
+		{
+			name:    "no tenant",
+			ctx:     func(int) context.Context { return context.Background() },
+			want:    []string{"order:bk_1"},
+			wantRun: 1,
+		},
+		{
+			name:    "same tenant",
+			ctx:     func(int) context.Context { return WithTenant(context.Background(), "acme") },
+			want:    []string{"acme/order:bk_1"},
+			wantRun: 1,
+		},
+		{
+			name:    "different tenants",
+			ctx:     func(i int) context.Context { return WithTenant(context.Background(), []string{"acme", "globex"}[i]) },
+			want:    []string{"acme/order:bk_1", "globex/order:bk_1"},
+			wantRun: 2,
+		},
+		{
+			name:    "scope is escaped",
+			ctx:     func(int) context.Context { return WithTenant(context.Background(), "a/order:b") },
+			want:    []string{"a%2Forder:b/order:bk_1"},
+			wantRun: 1,
+		},
+		{
+			name: "custom scope",
+			opts: []IdempotencyOption{WithKeyScope(merchant)},
+			ctx: func(i int) context.Context {
+				return context.WithValue(context.Background(), merchantKey{}, []string{"m1", "m2"}[i])
+			},
+			want:    []string{"m1/order:bk_1", "m2/order:bk_1"},
+			wantRun: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &orderGateway{}
+			store := &recordingStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore()}
+			gw := NewIdempotentPaymentGateway(inner, store, time.Hour, tt.opts...)
+			for i := 0; i < 2; i++ {
+				if _, err := gw.CreateOrder(tt.ctx(i), CreateOrderRequest{Amount: 100, Currency: "INR", Receipt: "bk_1"}); err != nil {
+					t.Fatalf("call %d: %v", i, err)
+				}
+			}
+			if !reflect.DeepEqual(store.keys, tt.want) {
+				t.Fatalf("stored keys %q, want %q", store.keys, tt.want)
+			}
+			if got := inner.calls.Load(); got != tt.wantRun {
+				t.Fatalf("gateway called %d times, want %d", got, tt.wantRun)
+			}
+		})
+	}
+}
+
+type merchantKey struct{}
@@ -0,0 +1,311 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+// Operation names passed to interceptors in Invocation.Operation
+const (
+	OpCreateOrder            = "CreateOrder"
+	OpVerifyPayment          = "VerifyPayment"
+	OpGetPaymentStatus       = "GetPaymentStatus"
+	OpInitiateRefund         = "InitiateRefund"
+	OpGetRefundStatus        = "GetRefundStatus"
+	OpListRefunds            = "ListRefunds"
+	OpCapturePayment         = "CapturePayment"
+	OpVoidPayment            = "VoidPayment"
+	OpVerifyWebhookSignature = "VerifyWebhookSignature"
+	OpParseWebhookEvent      = "ParseWebhookEvent"
+	OpClientCredentials      = "ClientCredentials"
+	OpHealthCheck            = "HealthCheck"
+
+	OpCreateContact     = "CreateContact"
+	OpUpdateContact     = "UpdateContact"
+	OpCreateFundAccount = "CreateFundAccount"
+	OpInitiatePayout    = "InitiatePayout"
+	OpGetPayoutStatus   = "GetPayoutStatus"
+)
+
+// Invocation describes a gateway call as seen by an interceptor.
+//
+// Request holds the operation's input: the request struct for most
+// operations, the ID string for GetPaymentStatus and GetPayoutStatus, an
+// UpdateContactCall for UpdateContact, a WebhookPayload for the webhook
+// operations and nil for ClientCredentials and HealthCheck.
+type Invocation struct {
+	Gateway   string // registered or adapter name of the gateway being called
+	Operation string // one of the Op* constants
+	Request   interface{}
+	Dynamic   bool // true when a dynamic switcher chose the gateway
+}
+
+// UpdateContactCall is the Invocation.Request of an UpdateContact call
+type UpdateContactCall struct {
+	ContactID string
+	Contact   CreateContactRequest
+}
+
+// WebhookPayload is the Invocation.Request of the webhook operations
+type WebhookPayload struct {
+	Payload []byte
+	Headers map[string]string // nil for ParseWebhookEvent
+}
+
+// Handler performs the (rest of the) call. The returned value has the
+// operation's result type, e.g. *CreateOrderResponse or bool.
+type Handler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// Interceptor wraps a gateway call. It may inspect or replace the request and
+// response, and must call next to continue the call. The request passed to
+// next must keep the type described on Invocation.
+type Interceptor func(ctx context.Context, inv *Invocation, next Handler) (interface{}, error)
+
+// ChainInterceptors composes interceptors so that the first one is the
+// outermost. It returns nil when given no interceptors.
+func ChainInterceptors(interceptors ...Interceptor) Interceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+	return func(ctx context.Context, inv *Invocation, next Handler) (interface{}, error) {
+		h := next
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, inner := interceptors[i], h
+			h = func(ctx context.Context, req interface{}) (interface{}, error) {
+				inv.Request = req
+				return ic(ctx, inv, inner)
+			}
+		}
+		return h(ctx, inv.Request)
+	}
+}
+
+// WithInterceptors makes the dynamic switchers run every call to the resolved
+// adapter through the given interceptors, in order, with Invocation.Dynamic set.
+func WithInterceptors(interceptors ...Interceptor) SwitcherOption {
+	return func(o *switcherOptions) { o.interceptor = ChainInterceptors(interceptors...) }
+}
+
+// invoke runs fn through the interceptor ic (which may be nil), converting
+// between the typed call and the untyped Handler.
+func invoke[Req, Resp any](ctx context.Context, ic Interceptor, inv Invocation, fn func(context.Context, Req) (Resp, error)) (Resp, error) {
+	if ic == nil {
+		req, _ := inv.Request.(Req)
+		return fn(ctx, req)
+	}
+	h := func(ctx context.Context, req interface{}) (interface{}, error) {
+		r, ok := req.(Req)
+		if !ok && req != nil {
+			return nil, fmt.Errorf("pg: %s: interceptor passed request of type %T, want %T", inv.Operation, req, r)
+		}
+		return fn(ctx, r)
+	}
+	out, err := ic(ctx, &inv, h)
+	resp, ok := out.(Resp)
+	if err != nil {
+		return resp, err
+	}
+	if !ok {
+		return resp, fmt.Errorf("pg: %s: interceptor returned result of type %T, want %T", inv.Operation, out, resp)
+	}
+	return resp, nil
+}
+
+// --- Wrapped gateways ---
+
+// WrapPaymentGateway returns a PaymentGateway that runs every call to gw
+// through the interceptors, in order. Name is passed through untouched. The
+// wrapper also implements HealthChecker, reporting Skipped when gw does not,
+// WebhookSecretVerifier, RefundQuerier and PaymentCapturer, the latter two
+// failing with ErrNotImplemented when gw does not implement them.
+func WrapPaymentGateway(gw PaymentGateway, interceptors ...Interceptor) PaymentGateway {
+	return &interceptedPaymentGateway{gw: gw, ic: ChainInterceptors(interceptors...)}
+}
+
+type interceptedPaymentGateway struct {
+	gw PaymentGateway
+	ic Interceptor
+}
+
+func (w *interceptedPaymentGateway) inv(op string, req interface{}) Invocation {
+	return Invocation{Gateway: w.gw.Name(), Operation: op, Request: req}
+}
+
+func (w *interceptedPaymentGateway) Name() string { return w.gw.Name() }
+
+func (w *interceptedPaymentGateway) CreateOrder(ctx context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	return invoke(ctx, w.ic, w.inv(OpCreateOrder, req), w.gw.CreateOrder)
+}
+
+func (w *interceptedPaymentGateway) VerifyPayment(ctx context.Context, req VerifyPaymentRequest) (bool, error) {
+	return invoke(ctx, w.ic, w.inv(OpVerifyPayment, req), w.gw.VerifyPayment)
+}
+
+func (w *interceptedPaymentGateway) GetPaymentStatus(ctx context.Context, gatewayOrderID string) (*PaymentStatus, error) {
+	return invoke(ctx, w.ic, w.inv(OpGetPaymentStatus, gatewayOrderID), w.gw.GetPaymentStatus)
+}
+
+func (w *interceptedPaymentGateway) InitiateRefund(ctx context.Context, req RefundRequest) (*RefundResponse, error) {
+	return invoke(ctx, w.ic, w.inv(OpInitiateRefund, req), w.gw.InitiateRefund)
+}
+
+func (w *interceptedPaymentGateway) GetRefundStatus(ctx context.Context, req RefundLookup) (*Refund, error) {
+	return invoke(ctx, w.ic, w.inv(OpGetRefundStatus, req), getRefundStatusFunc(w.gw, w.gw.Name()))
+}
+
+func (w *interceptedPaymentGateway) ListRefunds(ctx context.Context, req RefundLookup) ([]Refund, error) {
+	return invoke(ctx, w.ic, w.inv(OpListRefunds, req), listRefundsFunc(w.gw, w.gw.Name()))
+}
+
+func (w *interceptedPaymentGateway) CapturePayment(ctx context.Context, req CaptureRequest) (*PaymentStatus, error) {
+	return invoke(ctx, w.ic, w.inv(OpCapturePayment, req), capturePaymentFunc(w.gw, w.gw.Name()))
+}
+
+func (w *interceptedPaymentGateway) VoidPayment(ctx context.Context, req VoidRequest) (*PaymentStatus, error) {
+	return invoke(ctx, w.ic, w.inv(OpVoidPayment, req), voidPaymentFunc(w.gw, w.gw.Name()))
+}
+
+func (w *interceptedPaymentGateway) supportsManualCapture() bool { return supportsManualCapture(w.gw) }
+
+func (w *interceptedPaymentGateway) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, ok := w.VerifyWebhook(payload, headers)
+	return ok
+}
+
+func (w *interceptedPaymentGateway) VerifyWebhook(payload []byte, headers map[string]string) (string, bool) {
+	var secretID string
+	ok, _ := invoke(context.Background(), w.ic, w.inv(OpVerifyWebhookSignature, WebhookPayload{payload, headers}), verifyWebhookFunc(w.gw, &secretID))
+	return secretID, ok
+}
+
+func (w *interceptedPaymentGateway) ParseWebhookEvent(payload []byte) (*WebhookEvent, error) {
+	return invoke(context.Background(), w.ic, w.inv(OpParseWebhookEvent, WebhookPayload{Payload: payload}),
+		func(_ context.Context, p WebhookPayload) (*WebhookEvent, error) {
+			return w.gw.ParseWebhookEvent(p.Payload)
+		})
+}
+
+func (w *interceptedPaymentGateway) ClientCredentials() map[string]interface{} {
+	creds, _ := invoke(context.Background(), w.ic, w.inv(OpClientCredentials, nil), clientCredentialsFunc(w.gw))
+	return creds
+}
+
+func (w *interceptedPaymentGateway) HealthCheck(ctx context.Context) HealthCheckResult {
+	res, err := invoke(ctx, w.ic, w.inv(OpHealthCheck, nil), healthCheckFunc(w.gw, w.gw.Name()))
+	return healthResult(res, err, w.gw.Name())
+}
+
+// WrapPayoutGateway returns a PayoutGateway that runs every call to gw
+// through the interceptors, in order. Name and IsManual are passed through
+// untouched. The wrapper also implements HealthChecker and
+// WebhookSecretVerifier.
+func WrapPayoutGateway(gw PayoutGateway, interceptors ...Interceptor) PayoutGateway {
+	return &interceptedPayoutGateway{gw: gw, ic: ChainInterceptors(interceptors...)}
+}
+
+type interceptedPayoutGateway struct {
+	gw PayoutGateway
+	ic Interceptor
+}
+
+func (w *interceptedPayoutGateway) inv(op string, req interface{}) Invocation {
+	return Invocation{Gateway: w.gw.Name(), Operation: op, Request: req}
+}
+
+func (w *interceptedPayoutGateway) Name() string   { return w.gw.Name() }
+func (w *interceptedPayoutGateway) IsManual() bool { return w.gw.IsManual() }
+
+func (w *interceptedPayoutGateway) CreateContact(ctx context.Context, req CreateContactRequest) (*ContactResponse, error) {
+	return invoke(ctx, w.ic, w.inv(OpCreateContact, req), w.gw.CreateContact)
+}
+
+func (w *interceptedPayoutGateway) UpdateContact(ctx context.Context, contactID string, req CreateContactRequest) (*ContactResponse, error) {
+	return invoke(ctx, w.ic, w.inv(OpUpdateContact, UpdateContactCall{contactID, req}), updateContactFunc(w.gw))
+}
+
+func (w *interceptedPayoutGateway) CreateFundAccount(ctx context.Context, req CreateFundAccountRequest) (*FundAccountResponse, error) {
+	return invoke(ctx, w.ic, w.inv(OpCreateFundAccount, req), w.gw.CreateFundAccount)
+}
+
+func (w *interceptedPayoutGateway) InitiatePayout(ctx context.Context, req InitiatePayoutRequest) (*PayoutResponse, error) {
+	return invoke(ctx, w.ic, w.inv(OpInitiatePayout, req), w.gw.InitiatePayout)
+}
+
+func (w *interceptedPayoutGateway) GetPayoutStatus(ctx context.Context, gatewayPayoutID string) (*PayoutStatusResponse, error) {
+	return invoke(ctx, w.ic, w.inv(OpGetPayoutStatus, gatewayPayoutID), w.gw.GetPayoutStatus)
+}
+
+func (w *interceptedPayoutGateway) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, ok := w.VerifyWebhook(payload, headers)
+	return ok
+}
+
+func (w *interceptedPayoutGateway) VerifyWebhook(payload []byte, headers map[string]string) (string, bool) {
+	var secretID string
+	ok, _ := invoke(context.Background(), w.ic, w.inv(OpVerifyWebhookSignature, WebhookPayload{payload, headers}), verifyWebhookFunc(w.gw, &secretID))
+	return secretID, ok
+}
+
+func (w *interceptedPayoutGateway) ParseWebhookEvent(payload []byte) (*PayoutWebhookEvent, error) {
+	return invoke(context.Background(), w.ic, w.inv(OpParseWebhookEvent, WebhookPayload{Payload: payload}),
+		func(_ context.Context, p WebhookPayload) (*PayoutWebhookEvent, error) {
+			return w.gw.ParseWebhookEvent(p.Payload)
+		})
+}
+
+func (w *interceptedPayoutGateway) HealthCheck(ctx context.Context) HealthCheckResult {
+	res, err := invoke(ctx, w.ic, w.inv(OpHealthCheck, nil), healthCheckFunc(w.gw, w.gw.Name()))
+	return healthResult(res, err, w.gw.Name())
+}
+
+// --- typed adapters for methods whose shape differs from func(ctx, req) ---
+
+type webhookVerifier interface {
+	VerifyWebhookSignature(payload []byte, headers map[string]string) bool
+}
+
+// verifyWebhookFunc checks the signature, storing the ID of the matching
+// secret (if the adapter reports one) in secretID.
+func verifyWebhookFunc(gw webhookVerifier, secretID *string) func(context.Context, WebhookPayload) (bool, error) {
+	return func(_ context.Context, p WebhookPayload) (bool, error) {
+		id, ok := verifyWebhookWithID(gw, p.Payload, p.Headers)
+		*secretID = id
+		return ok, nil
+	}
+}
+
+func clientCredentialsFunc(gw PaymentGateway) func(context.Context, interface{}) (map[string]interface{}, error) {
+	return func(context.Context, interface{}) (map[string]interface{}, error) {
+		return gw.ClientCredentials(), nil
+	}
+}
+
+func updateContactFunc(gw PayoutGateway) func(context.Context, UpdateContactCall) (*ContactResponse, error) {
+	return func(ctx context.Context, c UpdateContactCall) (*ContactResponse, error) {
+		return gw.UpdateContact(ctx, c.ContactID, c.Contact)
+	}
+}
+
+// healthCheckFunc runs gw's health check, reporting Skipped when gw has none.
+// The result's Err is also returned so interceptors can observe failures.
+func healthCheckFunc(gw interface{}, name string) func(context.Context, interface{}) (HealthCheckResult, error) {
+	return func(ctx context.Context, _ interface{}) (HealthCheckResult, error) {
+		hc, ok := gw.(HealthChecker)
+		if !ok {
+			return HealthCheckResult{Gateway: name, Healthy: true, Skipped: true}, nil
+		}
+		res := hc.HealthCheck(ctx)
+		return res, res.Err
+	}
+}
+
+// healthResult fills in a result an interceptor cut short with an error.
+func healthResult(res HealthCheckResult, err error, name string) HealthCheckResult {
+	if err != nil && res.Err == nil {
+		res = HealthCheckResult{Gateway: name, Err: err}
+	}
+	return res
+}
@@ -14,6 +14,12 @@ import (
 // Adapter implements pg.PayoutGateway for manual payouts
 type Adapter struct{}
 
+func init() {
+	pg.RegisterPayoutDriver("manual", func(pg.Config) (pg.PayoutGateway, error) {
+		return New(), nil
+	})
+}
+
 // New creates a new manual PayoutGateway adapter
 func New() *Adapter { return &Adapter{} }
 
@@ -42,6 +48,7 @@ func (a *Adapter) CreateFundAccount(_ context.Context, req pg.CreateFundAccountR
 func (a *Adapter) InitiatePayout(_ context.Context, req pg.InitiatePayoutRequest) (*pg.PayoutResponse, error) {
 	return &pg.PayoutResponse{
 		GatewayPayoutID: "manual_" + req.ReferenceID,
+		State:           pg.PayoutStateManual,
 		Status:          "pending_manual",
 	}, nil
 }
@@ -50,6 +57,7 @@ func (a *Adapter) InitiatePayout(_ context.Context, req pg.InitiatePayoutRequest
 func (a *Adapter) GetPayoutStatus(_ context.Context, gatewayPayoutID string) (*pg.PayoutStatusResponse, error) {
 	return &pg.PayoutStatusResponse{
 		GatewayPayoutID: gatewayPayoutID,
+		State:           pg.PayoutStateManual,
 		Status:          "pending_manual",
 	}, nil
 }
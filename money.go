@@ -0,0 +1,165 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedCurrency is returned for currency codes missing from the ISO
+// 4217 table or not accepted by a gateway. It is always reported together
+// with ErrInvalidRequest.
+var ErrUnsupportedCurrency = errors.New("pg: unsupported currency")
+
+// currencyExponents holds the number of minor-unit digits of every ISO 4217
+// currency with a minor unit, as published by the ISO 4217 maintenance
+// agency. Codes without one, such as XAU or XDR, are left out.
+var currencyExponents = map[string]int{
+	// Zero-decimal currencies
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0,
+	"XPF": 0,
+
+	// Three-decimal currencies
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+
+	// Four-decimal currencies (Chilean and Uruguayan units of account)
+	"CLF": 4, "UYW": 4,
+
+	// Two-decimal currencies
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "AOA": 2, "ARS": 2, "AUD": 2, "AWG": 2,
+	"AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BMD": 2, "BND": 2, "BOB": 2,
+	"BOV": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2,
+	"CDF": 2, "CHE": 2, "CHF": 2, "CHW": 2, "CNY": 2, "COP": 2, "COU": 2, "CRC": 2,
+	"CUP": 2, "CVE": 2, "CZK": 2, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2, "ERN": 2,
+	"ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2,
+	"GMD": 2, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2,
+	"ILS": 2, "INR": 2, "IRR": 2, "JMD": 2, "KES": 2, "KGS": 2, "KHR": 2, "KPW": 2,
+	"KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2, "MAD": 2,
+	"MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2, "MRU": 2, "MUR": 2,
+	"MVR": 2, "MWK": 2, "MXN": 2, "MXV": 2, "MYR": 2, "MZN": 2, "NAD": 2, "NGN": 2,
+	"NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "PAB": 2, "PEN": 2, "PGK": 2, "PHP": 2,
+	"PKR": 2, "PLN": 2, "QAR": 2, "RON": 2, "RSD": 2, "RUB": 2, "SAR": 2, "SBD": 2,
+	"SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2, "SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2,
+	"SSP": 2, "STN": 2, "SVC": 2, "SYP": 2, "SZL": 2, "THB": 2, "TJS": 2, "TMT": 2,
+	"TOP": 2, "TRY": 2, "TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "USD": 2, "USN": 2,
+	"UYU": 2, "UZS": 2, "VED": 2, "VES": 2, "WST": 2, "XCD": 2, "XCG": 2, "YER": 2,
+	"ZAR": 2, "ZMW": 2, "ZWG": 2,
+
+	// Withdrawn codes still seen in the wild, kept so old records parse
+	"ANG": 2, "CUC": 2, "HRK": 2, "SLL": 2, "ZWL": 2,
+}
+
+// CurrencyExponent returns the number of minor-unit digits of an ISO 4217
+// currency code, e.g. 2 for INR, 0 for JPY and 3 for KWD.
+func CurrencyExponent(currency string) (int, bool) {
+	exp, ok := currencyExponents[currency]
+	return exp, ok
+}
+
+// Money is an amount in the minor unit of its currency, e.g. paise for INR.
+// The int64 Amount and string Currency fields of the request, response and
+// webhook types are also available as Money through their Money methods.
+type Money struct {
+	Amount   int64  // in the currency's minor unit
+	Currency string // ISO 4217 code, e.g. "INR"
+}
+
+// NewMoney returns amount minor units of currency, which must be supported.
+func NewMoney(amount int64, currency string) (Money, error) {
+	m := Money{Amount: amount, Currency: currency}
+	if err := m.Validate(); err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
+
+// ParseMoney parses a decimal amount in major units, e.g. "100.50" INR or
+// "1.005" KWD, without going through floating point. It rejects more
+// fractional digits than the currency has.
+func ParseMoney(amount, currency string) (Money, error) {
+	exp, ok := CurrencyExponent(currency)
+	if !ok {
+		return Money{}, unsupportedCurrency(currency)
+	}
+	sign, s := "", amount
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || strings.Trim(whole+frac, "0123456789") != "" {
+		return Money{}, fmt.Errorf("pg: parse amount %q: not a decimal number", amount)
+	}
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("pg: parse amount %q: %s has %d decimal places", amount, currency, exp)
+	}
+	minor, err := strconv.ParseInt(sign+whole+frac+strings.Repeat("0", exp-len(frac)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("pg: parse amount %q: out of range", amount)
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// Validate reports whether the currency is supported.
+func (m Money) Validate() error {
+	if _, ok := CurrencyExponent(m.Currency); !ok {
+		return unsupportedCurrency(m.Currency)
+	}
+	return nil
+}
+
+// Decimal formats the amount in major units with exactly as many decimal
+// places as the currency has, e.g. "100.50" for 10050 INR and "100" for 100
+// JPY. Unsupported currencies are formatted with two decimal places.
+func (m Money) Decimal() string {
+	exp, ok := CurrencyExponent(m.Currency)
+	if !ok {
+		exp = 2
+	}
+	var digits string
+	if m.Amount < 0 {
+		digits = strconv.FormatUint(uint64(-(m.Amount+1))+1, 10)
+	} else {
+		digits = strconv.FormatInt(m.Amount, 10)
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	if exp > 0 {
+		digits = digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+	}
+	if m.Amount < 0 {
+		digits = "-" + digits
+	}
+	return digits
+}
+
+// String formats m as e.g. "100.50 INR".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// CurrencySupporter is implemented by adapters that accept only some
+// currencies. Adapters without it accept every supported currency.
+type CurrencySupporter interface {
+	SupportsCurrency(currency string) bool
+}
+
+// CheckCurrency returns an error wrapping ErrInvalidRequest and
+// ErrUnsupportedCurrency unless currency is supported by CurrencyExponent
+// and, if gw implements CurrencySupporter, by gw. Adapters call it before
+// sending amounts to the gateway.
+func CheckCurrency(gw interface{ Name() string }, currency string) error {
+	if _, ok := CurrencyExponent(currency); !ok {
+		return unsupportedCurrency(currency)
+	}
+	if s, ok := gw.(CurrencySupporter); ok && !s.SupportsCurrency(currency) {
+		return fmt.Errorf("%w: %w %q on %s", ErrInvalidRequest, ErrUnsupportedCurrency, currency, gw.Name())
+	}
+	return nil
+}
+
+func unsupportedCurrency(currency string) error {
+	return fmt.Errorf("%w: %w %q", ErrInvalidRequest, ErrUnsupportedCurrency, currency)
+}
@@ -0,0 +1,156 @@
+package pg
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		fail     bool
+		wantErr  error // checked with errors.Is when set
+	}{
+		{"100.50", "INR", 10050, false, nil},
+		{"100", "INR", 10000, false, nil},
+		{"0.5", "INR", 50, false, nil},
+		{"0", "INR", 0, false, nil},
+		{"-1.25", "INR", -125, false, nil},
+		{"100", "JPY", 100, false, nil},
+		{"1.005", "KWD", 1005, false, nil},
+		{"1.5", "KWD", 1500, false, nil},
+		{"1.0001", "CLF", 10001, false, nil},
+		{"12.34", "TTD", 1234, false, nil},
+		{"92233720368547758.07", "INR", math.MaxInt64, false, nil},
+		{"-92233720368547758.08", "INR", math.MinInt64, false, nil},
+
+		{"1.5", "JPY", 0, true, nil},
+		{"1.234", "INR", 0, true, nil},
+		{"1.0001", "KWD", 0, true, nil},
+		{"92233720368547758.08", "INR", 0, true, nil},
+		{"", "INR", 0, true, nil},
+		{"-", "INR", 0, true, nil},
+		{".5", "INR", 0, true, nil},
+		{"1.2.3", "INR", 0, true, nil},
+		{"1e3", "INR", 0, true, nil},
+		{"+1", "INR", 0, true, nil},
+		{"1", "XXX", 0, true, ErrUnsupportedCurrency},
+		{"1", "", 0, true, ErrUnsupportedCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			got, err := ParseMoney(tt.amount, tt.currency)
+			if tt.fail {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q, %q) = %v, want error", tt.amount, tt.currency, got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseMoney(%q, %q) error = %v, want %v", tt.amount, tt.currency, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q, %q) error = %v", tt.amount, tt.currency, err)
+			}
+			if want := (Money{Amount: tt.want, Currency: tt.currency}); got != want {
+				t.Fatalf("ParseMoney(%q, %q) = %+v, want %+v", tt.amount, tt.currency, got, want)
+			}
+		})
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{10050, "INR"}, "100.50"},
+		{Money{5, "INR"}, "0.05"},
+		{Money{0, "INR"}, "0.00"},
+		{Money{-5, "INR"}, "-0.05"},
+		{Money{-12345, "INR"}, "-123.45"},
+		{Money{100, "JPY"}, "100"},
+		{Money{-100, "JPY"}, "-100"},
+		{Money{1005, "KWD"}, "1.005"},
+		{Money{5, "KWD"}, "0.005"},
+		{Money{12345, "CLF"}, "1.2345"},
+		{Money{math.MaxInt64, "INR"}, "92233720368547758.07"},
+		{Money{math.MinInt64, "INR"}, "-92233720368547758.08"},
+		{Money{150, "XXX"}, "1.50"}, // unsupported currencies use two decimals
+	}
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("%+v.Decimal() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyRoundTrip(t *testing.T) {
+	for _, currency := range []string{"INR", "JPY", "KWD", "CLF"} {
+		for _, amount := range []int64{0, 1, -1, 99, 100, 1001, -123456, math.MaxInt64, math.MinInt64} {
+			m := Money{Amount: amount, Currency: currency}
+			got, err := ParseMoney(m.Decimal(), currency)
+			if err != nil || got != m {
+				t.Errorf("ParseMoney(%q, %q) = %+v, %v; want %+v", m.Decimal(), currency, got, err, m)
+			}
+		}
+	}
+}
+
+type currencyGateway struct{ currencies map[string]bool }
+
+func (currencyGateway) Name() string                            { return "test" }
+func (g currencyGateway) SupportsCurrency(currency string) bool { return g.currencies[currency] }
+
+func TestCheckCurrency(t *testing.T) {
+	inrOnly := currencyGateway{map[string]bool{"INR": true}}
+	tests := []struct {
+		name     string
+		gw       interface{ Name() string }
+		currency string
+		ok       bool
+	}{
+		{"supported", inrOnly, "INR", true},
+		{"rejected by gateway", inrOnly, "USD", false},
+		{"unknown", inrOnly, "XXX", false},
+		{"empty", inrOnly, "", false},
+		{"no restriction", namedGateway("any"), "USD", true},
+		{"no restriction, less common code", namedGateway("any"), "KZT", true},
+		{"no restriction, unknown", namedGateway("any"), "XXX", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCurrency(tt.gw, tt.currency)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("CheckCurrency(%q) = %v, want nil", tt.currency, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidRequest) || !errors.Is(err, ErrUnsupportedCurrency) {
+				t.Fatalf("CheckCurrency(%q) = %v, want ErrInvalidRequest and ErrUnsupportedCurrency", tt.currency, err)
+			}
+		})
+	}
+}
+
+// namedGateway has no currency restriction.
+type namedGateway string
+
+func (n namedGateway) Name() string { return string(n) }
+
+func TestCurrencyExponentCoversISO4217(t *testing.T) {
+	for _, currency := range []string{"INR", "USD", "KZT", "BWP", "TTD", "MMK", "XCD", "ZWG"} {
+		if exp, ok := CurrencyExponent(currency); !ok || exp != 2 {
+			t.Errorf("CurrencyExponent(%q) = %d, %v; want 2, true", currency, exp, ok)
+		}
+	}
+	for _, currency := range []string{"", "XXX", "XAU", "XDR", "inr"} {
+		if _, ok := CurrencyExponent(currency); ok {
+			t.Errorf("CurrencyExponent(%q) reported a minor unit", currency)
+		}
+	}
+}
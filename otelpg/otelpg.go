@@ -0,0 +1,102 @@
+// Package otelpg adds OpenTelemetry tracing to pg gateways and switchers.
+//
+// It lives in its own module so that the core SDK does not depend on
+// OpenTelemetry. Spans are started from the ctx callers already pass, so they
+// join the caller's trace, and adapters that make HTTP calls with that ctx
+// (such as paytm with an HTTPClient from this package) nest their HTTP spans
+// underneath.
+package otelpg
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	pg "github.com/KriaaCompany/pg-switcher-sdk"
+)
+
+// ScopeName is the instrumentation scope used for spans
+const ScopeName = "github.com/KriaaCompany/pg-switcher-sdk/otelpg"
+
+// Span attribute keys
+const (
+	AttrGateway    = attribute.Key("pg.gateway")
+	AttrOperation  = attribute.Key("pg.operation")
+	AttrDynamic    = attribute.Key("pg.dynamic")
+	AttrAmount     = attribute.Key("pg.amount")
+	AttrCurrency   = attribute.Key("pg.currency")
+	AttrErrorClass = attribute.Key("pg.error.class")
+)
+
+type config struct {
+	provider trace.TracerProvider
+}
+
+// Option configures the interceptor
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider; the global provider is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.provider = tp }
+}
+
+// Interceptor returns a pg.Interceptor that wraps every gateway call in a
+// client span named "pg.<Operation>". Use it with pg.WrapPaymentGateway,
+// pg.WrapPayoutGateway or pg.WithInterceptors.
+func Interceptor(opts ...Option) pg.Interceptor {
+	cfg := config{provider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	tracer := cfg.provider.Tracer(ScopeName)
+
+	return func(ctx context.Context, inv *pg.Invocation, next pg.Handler) (interface{}, error) {
+		attrs := []attribute.KeyValue{
+			AttrGateway.String(inv.Gateway),
+			AttrOperation.String(inv.Operation),
+			AttrDynamic.Bool(inv.Dynamic),
+		}
+		attrs = append(attrs, requestAttributes(inv.Request)...)
+
+		ctx, span := tracer.Start(ctx, "pg."+inv.Operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		resp, err := next(ctx, inv.Request)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(AttrErrorClass.String(pg.ErrorClass(err)))
+		}
+		return resp, err
+	}
+}
+
+// requestAttributes extracts the amount and currency from requests that carry them
+func requestAttributes(req interface{}) []attribute.KeyValue {
+	switch r := req.(type) {
+	case pg.CreateOrderRequest:
+		return []attribute.KeyValue{AttrAmount.Int64(r.Amount), AttrCurrency.String(r.Currency)}
+	case pg.RefundRequest:
+		return []attribute.KeyValue{AttrAmount.Int64(r.Amount), AttrCurrency.String(r.Currency)}
+	case pg.CaptureRequest:
+		return []attribute.KeyValue{AttrAmount.Int64(r.Amount), AttrCurrency.String(r.Currency)}
+	case pg.InitiatePayoutRequest:
+		return []attribute.KeyValue{AttrAmount.Int64(r.Amount), AttrCurrency.String(r.Currency)}
+	}
+	return nil
+}
+
+// HTTPClient returns an http.Client whose requests are traced as child spans
+// of the ctx they are made with. Pass it as paytm.Config.HTTPClient or
+// paytm_payout.Config.HTTPClient.
+func HTTPClient(opts ...otelhttp.Option) *http.Client {
+	return &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport, opts...)}
+}
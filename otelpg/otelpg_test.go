@@ -0,0 +1,171 @@
+package otelpg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	pg "github.com/KriaaCompany/pg-switcher-sdk"
+)
+
+// fakeGateway answers CreateOrder and fails InitiateRefund with err.
+type fakeGateway struct {
+	pg.PaymentGateway
+	err error
+}
+
+func (fakeGateway) Name() string { return "fake" }
+
+func (fakeGateway) CreateOrder(_ context.Context, req pg.CreateOrderRequest) (*pg.CreateOrderResponse, error) {
+	return &pg.CreateOrderResponse{GatewayOrderID: "order_1", Amount: req.Amount, Currency: req.Currency}, nil
+}
+
+func (g fakeGateway) InitiateRefund(context.Context, pg.RefundRequest) (*pg.RefundResponse, error) {
+	return nil, g.err
+}
+
+func newRecorder() (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	return exporter, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+}
+
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	out := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		out[kv.Key] = kv.Value
+	}
+	return out
+}
+
+func TestInterceptorSpans(t *testing.T) {
+	errDeclined := &pg.GatewayError{Gateway: "fake", Kind: pg.ErrInvalidRequest, Message: "declined"}
+	tests := []struct {
+		name      string
+		dynamic   bool
+		call      func(ctx context.Context, gw pg.PaymentGateway) error
+		wantName  string
+		wantAttrs map[attribute.Key]attribute.Value
+		wantErr   bool
+	}{
+		{
+			name: "create order",
+			call: func(ctx context.Context, gw pg.PaymentGateway) error {
+				_, err := gw.CreateOrder(ctx, pg.CreateOrderRequest{Amount: 50000, Currency: "INR"})
+				return err
+			},
+			wantName: "pg.CreateOrder",
+			wantAttrs: map[attribute.Key]attribute.Value{
+				AttrGateway:   attribute.StringValue("fake"),
+				AttrOperation: attribute.StringValue(pg.OpCreateOrder),
+				AttrDynamic:   attribute.BoolValue(false),
+				AttrAmount:    attribute.Int64Value(50000),
+				AttrCurrency:  attribute.StringValue("INR"),
+			},
+		},
+		{
+			name:    "failed refund through a switcher",
+			dynamic: true,
+			call: func(ctx context.Context, gw pg.PaymentGateway) error {
+				_, err := gw.InitiateRefund(ctx, pg.RefundRequest{GatewayPaymentID: "pay_1", Amount: 100, Currency: "INR"})
+				return err
+			},
+			wantName: "pg.InitiateRefund",
+			wantAttrs: map[attribute.Key]attribute.Value{
+				AttrGateway:    attribute.StringValue("primary"),
+				AttrOperation:  attribute.StringValue(pg.OpInitiateRefund),
+				AttrDynamic:    attribute.BoolValue(true),
+				AttrAmount:     attribute.Int64Value(100),
+				AttrCurrency:   attribute.StringValue("INR"),
+				AttrErrorClass: attribute.StringValue(pg.ErrorClass(errDeclined)),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, tp := newRecorder()
+			interceptor := Interceptor(WithTracerProvider(tp))
+			var gw pg.PaymentGateway = pg.WrapPaymentGateway(fakeGateway{err: errDeclined}, interceptor)
+			if tt.dynamic {
+				gw = pg.NewDynamicPaymentSwitcher(map[string]pg.PaymentGateway{"primary": fakeGateway{err: errDeclined}},
+					pg.StaticResolver("primary"), pg.WithInterceptors(interceptor))
+			}
+
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+			err := tt.call(ctx, gw)
+			parent.End()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("call error = %v, want error %v", err, tt.wantErr)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("recorded %d spans, want the call's and its parent's", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tt.wantName || span.SpanKind != trace.SpanKindClient {
+				t.Fatalf("span = %q (kind %v), want client span %q", span.Name, span.SpanKind, tt.wantName)
+			}
+			if span.Parent.SpanID() != spans[1].SpanContext.SpanID() {
+				t.Fatal("span is not a child of the caller's span")
+			}
+			if span.InstrumentationScope.Name != ScopeName {
+				t.Fatalf("scope = %q, want %q", span.InstrumentationScope.Name, ScopeName)
+			}
+			got := attrs(span)
+			if len(got) != len(tt.wantAttrs) {
+				t.Errorf("attributes = %v, want %v", got, tt.wantAttrs)
+			}
+			for k, want := range tt.wantAttrs {
+				if got[k] != want {
+					t.Errorf("%s = %v, want %v", k, got[k].Emit(), want.Emit())
+				}
+			}
+			if tt.wantErr {
+				if span.Status.Code != codes.Error || len(span.Events) == 0 || span.Events[0].Name != "exception" {
+					t.Fatalf("status = %+v, events = %v; want an error status and a recorded exception", span.Status, span.Events)
+				}
+			} else if span.Status.Code != codes.Unset {
+				t.Fatalf("status = %+v, want unset", span.Status)
+			}
+		})
+	}
+}
+
+func TestInterceptorPassesErrorsThrough(t *testing.T) {
+	_, tp := newRecorder()
+	errBoom := errors.New("boom")
+	gw := pg.WrapPaymentGateway(fakeGateway{err: errBoom}, Interceptor(WithTracerProvider(tp)))
+	if _, err := gw.InitiateRefund(context.Background(), pg.RefundRequest{}); !errors.Is(err, errBoom) {
+		t.Fatalf("InitiateRefund error = %v, want errBoom", err)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	exporter, tp := newRecorder()
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "pg.CreateOrder")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, nil)
+	resp, err := HTTPClient(otelhttp.WithTracerProvider(tp)).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 || spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Fatalf("spans = %v, want an HTTP span under the caller's", spans)
+	}
+}
@@ -12,6 +12,32 @@ const (
 	PayoutWebhookEventUnknown   PayoutWebhookEventType = "unknown"
 )
 
+// PayoutState is the gateway-agnostic state of a payout
+type PayoutState string
+
+const (
+	PayoutStateQueued     PayoutState = "queued"     // accepted, waiting for balance or approval
+	PayoutStateProcessing PayoutState = "processing" // sent to the beneficiary's bank
+	PayoutStateProcessed  PayoutState = "processed"  // credited to the beneficiary
+	PayoutStateFailed     PayoutState = "failed"     // rejected by the bank; see the failure code
+	PayoutStateReversed   PayoutState = "reversed"   // credited, then returned by the bank
+	PayoutStateCancelled  PayoutState = "cancelled"  // cancelled or rejected before processing
+	PayoutStateManual     PayoutState = "manual"     // to be paid outside the gateway by an admin
+	PayoutStateUnknown    PayoutState = "unknown"    // gateway status not recognised
+)
+
+// PayoutFailureCode is the gateway-agnostic reason a payout failed or was reversed
+type PayoutFailureCode string
+
+const (
+	PayoutFailureInvalidBeneficiary  PayoutFailureCode = "invalid_beneficiary_account" // wrong, closed or frozen account, IFSC or VPA
+	PayoutFailureBeneficiaryBankDown PayoutFailureCode = "beneficiary_bank_down"       // beneficiary bank or network unavailable
+	PayoutFailureInsufficientBalance PayoutFailureCode = "insufficient_balance"        // payout account balance too low
+	PayoutFailureLimitExceeded       PayoutFailureCode = "limit_exceeded"              // amount or count limit of the mode, account or bank
+	PayoutFailureRejected            PayoutFailureCode = "rejected"                    // rejected during approval
+	PayoutFailureOther               PayoutFailureCode = "other"                       // any other reason; see FailureReason
+)
+
 // CreateContactRequest contains fields for creating a payout contact
 type CreateContactRequest struct {
 	Name        string
@@ -50,28 +76,43 @@ type InitiatePayoutRequest struct {
 	Narration     string
 }
 
+// Money returns Amount and Currency as Money.
+func (r InitiatePayoutRequest) Money() Money { return Money{Amount: r.Amount, Currency: r.Currency} }
+
 // PayoutResponse is returned after initiating a payout
 type PayoutResponse struct {
 	GatewayPayoutID string
-	Status          string // "processing", "pending_manual", etc.
+	State           PayoutState // normalised across gateways
+	Status          string      // gateway-specific status, e.g. "processing" or "pending_manual"
 }
 
 // PayoutStatusResponse is returned when querying a payout's status
 type PayoutStatusResponse struct {
 	GatewayPayoutID string
-	Status          string
-	FailureReason   string
+	State           PayoutState       // normalised across gateways
+	Status          string            // gateway-specific status
+	FailureCode     PayoutFailureCode // set when State is failed, reversed or cancelled
+	FailureReason   string            // gateway's description of the failure
 }
 
 // PayoutWebhookEvent is a normalised payout webhook event
 type PayoutWebhookEvent struct {
 	Type            PayoutWebhookEventType
 	GatewayPayoutID string
+	FailureCode     PayoutFailureCode // set for failed and reversed payouts
 	FailureReason   string
 	// Raw contains the original parsed payload
 	Raw map[string]interface{}
 }
 
+// VerifiedPayoutWebhook is a payout webhook event together with the gateway that verified it
+type VerifiedPayoutWebhook struct {
+	Gateway  string // registered name of the gateway whose signature check passed
+	SecretID string // ID of the webhook secret that matched, if the adapter reports it
+	TenantID string // tenant whose secret matched, set by the tenant switchers
+	Event    *PayoutWebhookEvent
+}
+
 // PayoutGateway is the common interface that all payout gateway adapters implement
 type PayoutGateway interface {
 	// Name returns the unique gateway identifier (e.g. "razorpayx", "paytm", "manual")
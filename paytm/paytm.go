@@ -13,6 +13,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"time"
 
 	pg "github.com/KriaaCompany/pg-switcher-sdk"
 )
@@ -20,16 +22,55 @@ import (
 const (
 	productionBase = "https://securegw.paytm.in"
 	stagingBase    = "https://securegw-stage.paytm.in"
+
+	// resultCodeSystemError is returned by Paytm for transient failures on its side
+	resultCodeSystemError = "501"
+	// resultCodeChecksumMismatch means the request signature did not verify
+	resultCodeChecksumMismatch = "330"
+	// resultCodeInvalidMID means the merchant ID is unknown to Paytm
+	resultCodeInvalidMID = "2006"
+	// resultCodeDuplicateOrder means the orderId was already used
+	resultCodeDuplicateOrder = "325"
+	// resultCodeOrderNotFound means the orderId is unknown to Paytm
+	resultCodeOrderNotFound = "334"
+	// resultCodeRefundAlreadyRaised means a refund with the same refId exists
+	resultCodeRefundAlreadyRaised = "617"
+	// resultCodeInvalidRefundAmount means the amount exceeds what is refundable
+	resultCodeInvalidRefundAmount = "619"
+	// resultCodeRefundNotFound means no refund exists for the orderId and refId
+	resultCodeRefundNotFound = "631"
 )
 
+// resultCodeKinds maps Paytm resultCodes that describe an API failure, rather
+// than the outcome of a transaction, onto the pg error kinds.
+var resultCodeKinds = map[string]error{
+	resultCodeSystemError:      pg.ErrGatewayUnavailable,
+	resultCodeChecksumMismatch: pg.ErrUnauthenticated,
+	resultCodeInvalidMID:       pg.ErrUnauthenticated,
+	resultCodeDuplicateOrder:   pg.ErrInvalidRequest,
+	resultCodeOrderNotFound:    pg.ErrNotFound,
+
+	resultCodeInvalidRefundAmount: pg.ErrInvalidRequest,
+	resultCodeRefundNotFound:      pg.ErrNotFound,
+}
+
 // Config holds Paytm payment gateway credentials.
-type Config struct {
-	MID           string // Merchant ID
-	MerchantKey   string
-	Website       string // e.g. "WEBSTAGING" or "DEFAULT"
-	CallbackURL   string
-	WebhookSecret string
-	Production    bool
+type Config = pg.PaytmConfig
+
+func init() {
+	pg.RegisterPaymentDriver("paytm", func(cfg pg.Config) (pg.PaymentGateway, error) {
+		if cfg.Paytm.MID == "" {
+			return nil, pg.ErrNotConfigured
+		}
+		c := cfg.Paytm
+		if c.Secrets == nil {
+			c.Secrets = cfg.Secrets
+		}
+		if c.OnSecretError == nil {
+			c.OnSecretError = cfg.OnSecretError
+		}
+		return New(c), nil
+	})
 }
 
 // Adapter implements pg.PaymentGateway for Paytm.
@@ -38,9 +79,24 @@ type Adapter struct {
 	client *http.Client
 }
 
-// New creates a new Paytm PaymentGateway adapter.
+// New creates a new Paytm PaymentGateway adapter. When cfg.Secrets is set, the
+// merchant key and webhook secret are looked up on every call, so rotated
+// keys take effect without creating a new adapter.
 func New(cfg Config) *Adapter {
-	return &Adapter{cfg: cfg, client: &http.Client{}}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Adapter{cfg: cfg, client: client}
+}
+
+// merchantKey returns the current merchant key.
+func (a *Adapter) merchantKey(ctx context.Context) (string, error) {
+	key, err := pg.LookupSecret(ctx, a.cfg.Secrets, pg.SecretPaytmMerchantKey, a.cfg.MerchantKey)
+	if err != nil {
+		return "", fmt.Errorf("paytm: %w", err)
+	}
+	return key, nil
 }
 
 // Name returns the gateway identifier.
@@ -94,10 +150,24 @@ type initiateResponse struct {
 	} `json:"body"`
 }
 
+// SupportsCurrency reports whether Paytm accepts payments in currency; only
+// INR is supported.
+func (a *Adapter) SupportsCurrency(currency string) bool { return currency == "INR" }
+
 // CreateOrder calls Paytm's initiateTransaction API and returns the txn_token
-// required by the mobile AllInOne SDK.
+// required by the mobile AllInOne SDK. The transactions it starts are
+// captured on payment, so AuthorizeOnly orders fail with pg.ErrNotImplemented.
 func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*pg.CreateOrderResponse, error) {
-	amountRupees := fmt.Sprintf("%.2f", float64(req.Amount)/100.0)
+	if req.AuthorizeOnly {
+		return nil, fmt.Errorf("paytm: AuthorizeOnly: %w", pg.ErrNotImplemented)
+	}
+	if err := pg.CheckCurrency(a, req.Currency); err != nil {
+		return nil, fmt.Errorf("paytm: %w", err)
+	}
+	key, err := a.merchantKey(ctx)
+	if err != nil {
+		return nil, err
+	}
 	website := a.cfg.Website
 	if website == "" {
 		if a.cfg.Production {
@@ -112,7 +182,7 @@ func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*
 		MID:         a.cfg.MID,
 		WebsiteName: website,
 		OrderID:     req.Receipt,
-		TxnAmount:   txnAmount{Value: amountRupees, Currency: req.Currency},
+		TxnAmount:   txnAmount{Value: req.Money().Decimal(), Currency: req.Currency},
 		UserInfo:    userInfo{CustID: "anonymous"},
 		CallbackURL: a.cfg.CallbackURL,
 	}
@@ -128,7 +198,7 @@ func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*
 			Version:   "v1",
 			ChannelID: "WAP",
 			TokenType: "AES",
-			Signature: computeSignature(string(bodyJSON), a.cfg.MerchantKey),
+			Signature: computeSignature(string(bodyJSON), key),
 		},
 	}
 
@@ -137,11 +207,7 @@ func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*
 		return nil, fmt.Errorf("paytm: marshal request: %w", err)
 	}
 
-	base := stagingBase
-	if a.cfg.Production {
-		base = productionBase
-	}
-	url := fmt.Sprintf("%s/theia/api/v1/initiateTransaction?mid=%s&orderId=%s", base, a.cfg.MID, req.Receipt)
+	url := fmt.Sprintf("%s/theia/api/v1/initiateTransaction?mid=%s&orderId=%s", a.baseURL(), a.cfg.MID, req.Receipt)
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payloadJSON))
 	if err != nil {
@@ -151,9 +217,12 @@ func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*
 
 	resp, err := a.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("paytm: HTTP request: %w", err)
+		return nil, fmt.Errorf("paytm: HTTP request: %w", transportError(err))
 	}
 	defer resp.Body.Close()
+	if err := httpError(resp.StatusCode); err != nil {
+		return nil, fmt.Errorf("paytm: initiateTransaction failed: %w", err)
+	}
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -165,9 +234,8 @@ func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*
 		return nil, fmt.Errorf("paytm: decode response: %w", err)
 	}
 
-	if txnResp.Body.ResultInfo.ResultStatus != "S" {
-		return nil, fmt.Errorf("paytm: order creation failed: %s (code %s)",
-			txnResp.Body.ResultInfo.ResultMsg, txnResp.Body.ResultInfo.ResultCode)
+	if info := txnResp.Body.ResultInfo; info.ResultStatus != "S" {
+		return nil, fmt.Errorf("paytm: order creation failed: %w", resultError(info.ResultCode, info.ResultMsg, pg.ErrInvalidRequest))
 	}
 	if txnResp.Body.TxnToken == "" {
 		return nil, fmt.Errorf("paytm: empty txn_token in response")
@@ -175,6 +243,7 @@ func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*
 
 	return &pg.CreateOrderResponse{
 		GatewayOrderID: req.Receipt, // Paytm uses our orderId as the identifier
+		Gateway:        a.Name(),
 		Amount:         req.Amount,
 		Currency:       req.Currency,
 		Extra: map[string]interface{}{
@@ -186,101 +255,550 @@ func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*
 
 // ─── VerifyPayment ───────────────────────────────────────────────────────────
 
-// VerifyPayment confirms a Paytm payment by querying the order status API
-// server-side (more reliable than client-checksum verification).
-func (a *Adapter) VerifyPayment(ctx context.Context, req pg.VerifyPaymentRequest) (bool, error) {
+// orderStatusResponse is the response of the /v3/order/status API.
+type orderStatusResponse struct {
+	Body struct {
+		ResultInfo struct {
+			ResultStatus string `json:"resultStatus"`
+			ResultCode   string `json:"resultCode"`
+			ResultMsg    string `json:"resultMsg"`
+		} `json:"resultInfo"`
+		TxnID     string `json:"txnId"`
+		TxnStatus string `json:"txnStatus"`
+		TxnAmount string `json:"txnAmount"`
+		TxnDate   string `json:"txnDate"`
+		RefundAmt string `json:"refundAmt"`
+	} `json:"body"`
+}
+
+// txnStatus returns the transaction status, e.g. "TXN_SUCCESS". The v3 API
+// reports it as the resultStatus; txnStatus is kept for older responses.
+func (r *orderStatusResponse) txnStatus() string {
+	if r.Body.TxnStatus != "" {
+		return r.Body.TxnStatus
+	}
+	return r.Body.ResultInfo.ResultStatus
+}
+
+// state maps the order status onto a pg.PaymentState. Successful payments
+// with a refund amount are reported as partially or fully refunded.
+func (r *orderStatusResponse) state() pg.PaymentState {
+	switch r.txnStatus() {
+	case "TXN_SUCCESS":
+		refunded, err := pg.ParseMoney(r.Body.RefundAmt, "INR")
+		if err != nil || refunded.Amount <= 0 {
+			return pg.PaymentStateCaptured
+		}
+		total, err := pg.ParseMoney(r.Body.TxnAmount, "INR")
+		if err == nil && refunded.Amount < total.Amount {
+			return pg.PaymentStatePartiallyRefunded
+		}
+		return pg.PaymentStateRefunded
+	case "PENDING":
+		return pg.PaymentStatePending
+	case "TXN_FAILURE":
+		return pg.PaymentStateFailed
+	}
+	return pg.PaymentStateUnknown
+}
+
+// fetchOrderStatus calls Paytm's order status API for orderID.
+func (a *Adapter) fetchOrderStatus(ctx context.Context, orderID string) (*orderStatusResponse, error) {
 	type statusBody struct {
 		MID     string `json:"mid"`
 		OrderID string `json:"orderId"`
 	}
 
-	body := statusBody{MID: a.cfg.MID, OrderID: req.GatewayOrderID}
+	key, err := a.merchantKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body := statusBody{MID: a.cfg.MID, OrderID: orderID}
 	bodyJSON, err := json.Marshal(body)
 	if err != nil {
-		return false, fmt.Errorf("paytm: marshal status body: %w", err)
+		return nil, fmt.Errorf("paytm: marshal status body: %w", err)
 	}
 
 	payload := map[string]interface{}{
 		"body": body,
 		"head": map[string]interface{}{
-			"signature": computeSignature(string(bodyJSON), a.cfg.MerchantKey),
+			"signature": computeSignature(string(bodyJSON), key),
 			"tokenType": "AES",
 		},
 	}
 
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
-		return false, fmt.Errorf("paytm: marshal status request: %w", err)
+		return nil, fmt.Errorf("paytm: marshal status request: %w", err)
 	}
 
-	base := stagingBase
-	if a.cfg.Production {
-		base = productionBase
-	}
-	url := fmt.Sprintf("%s/v3/order/status", base)
+	url := fmt.Sprintf("%s/v3/order/status", a.baseURL())
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payloadJSON))
 	if err != nil {
-		return false, fmt.Errorf("paytm: create status request: %w", err)
+		return nil, fmt.Errorf("paytm: create status request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := a.client.Do(httpReq)
 	if err != nil {
-		return false, fmt.Errorf("paytm: order status HTTP request: %w", err)
+		return nil, fmt.Errorf("paytm: order status HTTP request: %w", transportError(err))
 	}
 	defer resp.Body.Close()
-
-	var statusResp struct {
-		Body struct {
-			ResultInfo struct {
-				ResultStatus string `json:"resultStatus"`
-			} `json:"resultInfo"`
-			TxnStatus string `json:"txnStatus"`
-		} `json:"body"`
+	if err := httpError(resp.StatusCode); err != nil {
+		return nil, fmt.Errorf("paytm: order status failed: %w", err)
 	}
 
+	var statusResp orderStatusResponse
 	if err := json.NewDecoder(resp.Body).Decode(&statusResp); err != nil {
-		return false, fmt.Errorf("paytm: decode status response: %w", err)
+		return nil, fmt.Errorf("paytm: decode status response: %w", err)
 	}
+	return &statusResp, nil
+}
 
-	return statusResp.Body.TxnStatus == "TXN_SUCCESS", nil
+// VerifyPayment confirms a Paytm payment by querying the order status API
+// server-side (more reliable than client-checksum verification). Unknown
+// orders fail with pg.ErrNotFound.
+func (a *Adapter) VerifyPayment(ctx context.Context, req pg.VerifyPaymentRequest) (bool, error) {
+	statusResp, err := a.orderStatus(ctx, req.GatewayOrderID)
+	if err != nil {
+		return false, err
+	}
+	return statusResp.txnStatus() == "TXN_SUCCESS", nil
+}
+
+// orderStatus fetches the status of orderID, failing on API errors such as an
+// unknown order.
+func (a *Adapter) orderStatus(ctx context.Context, orderID string) (*orderStatusResponse, error) {
+	statusResp, err := a.fetchOrderStatus(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	info := statusResp.Body.ResultInfo
+	if _, ok := resultCodeKinds[info.ResultCode]; ok {
+		return nil, fmt.Errorf("paytm: order status failed: %w", resultError(info.ResultCode, info.ResultMsg, nil))
+	}
+	return statusResp, nil
+}
+
+// ─── HealthCheck ─────────────────────────────────────────────────────────────
+
+// healthProbeOrderID is an order ID that never exists; querying it exercises
+// signature verification without touching real transactions.
+const healthProbeOrderID = "pg-switcher-health-probe"
+
+// HealthCheck queries the status of a non-existent order. Paytm verifies the
+// request signature before looking the order up, so a checksum or MID error
+// means the credentials are wrong while "order not found" means they work.
+func (a *Adapter) HealthCheck(ctx context.Context) pg.HealthCheckResult {
+	start := time.Now()
+	statusResp, err := a.fetchOrderStatus(ctx, healthProbeOrderID)
+	res := pg.HealthCheckResult{Gateway: a.Name(), Latency: time.Since(start)}
+	if err != nil {
+		res.Err = fmt.Errorf("paytm: health check failed: %w", err)
+		return res
+	}
+	info := statusResp.Body.ResultInfo
+	switch info.ResultCode {
+	case resultCodeChecksumMismatch, resultCodeInvalidMID, resultCodeSystemError:
+		res.Err = fmt.Errorf("paytm: health check failed: %w", resultError(info.ResultCode, info.ResultMsg, nil))
+		return res
+	}
+	res.Healthy = true
+	return res
 }
 
 // ─── Other gateway methods ────────────────────────────────────────────────────
 
 // GetPaymentStatus queries a Paytm order's current status.
 func (a *Adapter) GetPaymentStatus(ctx context.Context, gatewayOrderID string) (*pg.PaymentStatus, error) {
-	ok, err := a.VerifyPayment(ctx, pg.VerifyPaymentRequest{GatewayOrderID: gatewayOrderID})
+	statusResp, err := a.orderStatus(ctx, gatewayOrderID)
 	if err != nil {
 		return nil, err
 	}
-	status := "TXN_FAILURE"
-	if ok {
-		status = "TXN_SUCCESS"
-	}
+	status := statusResp.txnStatus()
 	return &pg.PaymentStatus{
-		GatewayOrderID: gatewayOrderID,
-		Status:         status,
-		Paid:           ok,
+		GatewayOrderID:   gatewayOrderID,
+		GatewayPaymentID: statusResp.Body.TxnID,
+		State:            statusResp.state(),
+		Status:           status,
+		Paid:             status == "TXN_SUCCESS",
 	}, nil
 }
 
-// InitiateRefund initiates a Paytm refund via the Refund API.
-func (a *Adapter) InitiateRefund(_ context.Context, req pg.RefundRequest) (*pg.RefundResponse, error) {
-	// Paytm refund requires TXNID (GatewayPaymentID) and REFUNDID
-	// Full implementation follows Paytm's /refund/apply/v2 endpoint
-	return nil, fmt.Errorf("paytm: refund not yet implemented")
+// ─── Refunds ─────────────────────────────────────────────────────────────────
+
+// refundBody is the inner "body" of the /refund/apply request.
+type refundBody struct {
+	MID          string `json:"mid"`
+	TxnType      string `json:"txnType"`
+	OrderID      string `json:"orderId"`
+	TxnID        string `json:"txnId"`
+	RefID        string `json:"refId"`
+	RefundAmount string `json:"refundAmount"`
+}
+
+// refundResponse is the response of the /refund/apply and /v2/refund/status APIs.
+type refundResponse struct {
+	Body struct {
+		ResultInfo struct {
+			ResultStatus string `json:"resultStatus"`
+			ResultCode   string `json:"resultCode"`
+			ResultMsg    string `json:"resultMsg"`
+		} `json:"resultInfo"`
+		OrderID      string `json:"orderId"`
+		TxnID        string `json:"txnId"`
+		RefID        string `json:"refId"`
+		RefundID     string `json:"refundId"`
+		RefundAmount string `json:"refundAmount"`
+
+		// Only in /v2/refund/status responses
+		AcceptRefundTimestamp string `json:"acceptRefundTimestamp"`
+		RefundDetailInfoList  []struct {
+			RRN string `json:"rrn"`
+		} `json:"refundDetailInfoList"`
+	} `json:"body"`
+}
+
+// refund converts the response into a pg.RefundResponse. Paytm reports the
+// refund's status ("PENDING", "TXN_SUCCESS" or "TXN_FAILURE") as the
+// resultStatus.
+func (r *refundResponse) refund(currency string) *pg.RefundResponse {
+	out := &pg.RefundResponse{RefundID: r.Body.RefID, Currency: currency, Status: r.Body.ResultInfo.ResultStatus}
+	if m, err := pg.ParseMoney(r.Body.RefundAmount, currency); err == nil {
+		out.Amount = m.Amount
+	}
+	return out
+}
+
+// InitiateRefund refunds a Paytm payment through the /refund/apply API.
+//
+// req.GatewayOrderID and req.RefundID are required; RefundID becomes Paytm's
+// refId, which must be unique per refund, and falls back to IdempotencyKey.
+// A zero Amount refunds whatever remains of the transaction amount. When
+// Amount is zero or GatewayPaymentID is empty, the order status is fetched
+// first. Repeating a refund with the same RefundID returns the existing
+// refund. The response's RefundID is the refId, as taken by RefundStatus.
+func (a *Adapter) InitiateRefund(ctx context.Context, req pg.RefundRequest) (*pg.RefundResponse, error) {
+	refID := req.RefundID
+	if refID == "" {
+		refID = req.IdempotencyKey
+	}
+	if req.GatewayOrderID == "" || refID == "" {
+		return nil, fmt.Errorf("paytm: refund: %w: GatewayOrderID and RefundID are required", pg.ErrInvalidRequest)
+	}
+	if req.Amount < 0 {
+		return nil, fmt.Errorf("paytm: refund: %w: negative amount", pg.ErrInvalidRequest)
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = "INR" // the currency of every Paytm payment
+	}
+	if err := pg.CheckCurrency(a, currency); err != nil {
+		return nil, fmt.Errorf("paytm: %w", err)
+	}
+
+	amount := pg.Money{Amount: req.Amount, Currency: currency}
+	txnID := req.GatewayPaymentID
+	if txnID == "" || amount.Amount == 0 {
+		statusResp, err := a.orderStatus(ctx, req.GatewayOrderID)
+		if err != nil {
+			return nil, err
+		}
+		if txnID == "" {
+			txnID = statusResp.Body.TxnID
+		}
+		if amount.Amount == 0 {
+			total, err := pg.ParseMoney(statusResp.Body.TxnAmount, currency)
+			if err != nil {
+				return nil, fmt.Errorf("paytm: refund: transaction amount: %w", err)
+			}
+			refunded, _ := pg.ParseMoney(statusResp.Body.RefundAmt, currency)
+			amount.Amount = total.Amount - refunded.Amount
+			if amount.Amount <= 0 {
+				return nil, fmt.Errorf("paytm: refund: %w: nothing left to refund", pg.ErrInvalidRequest)
+			}
+		}
+	}
+
+	body := refundBody{
+		MID:          a.cfg.MID,
+		TxnType:      "REFUND",
+		OrderID:      req.GatewayOrderID,
+		TxnID:        txnID,
+		RefID:        refID,
+		RefundAmount: amount.Decimal(),
+	}
+	var resp refundResponse
+	if err := a.signedPost(ctx, "refund", "/refund/apply", body, &resp); err != nil {
+		return nil, err
+	}
+	info := resp.Body.ResultInfo
+	switch {
+	case info.ResultCode == resultCodeRefundAlreadyRaised:
+		return a.RefundStatus(ctx, req.GatewayOrderID, refID)
+	case info.ResultStatus != "PENDING" && info.ResultStatus != "TXN_SUCCESS":
+		return nil, fmt.Errorf("paytm: refund failed: %w", resultError(info.ResultCode, info.ResultMsg, pg.ErrInvalidRequest))
+	}
+	return resp.refund(currency), nil
+}
+
+// RefundStatus queries a refund through the /v2/refund/status API. refundID
+// is the refId passed to InitiateRefund. Unknown refunds fail with
+// pg.ErrNotFound.
+func (a *Adapter) RefundStatus(ctx context.Context, gatewayOrderID, refundID string) (*pg.RefundResponse, error) {
+	resp, err := a.refundStatus(ctx, gatewayOrderID, refundID)
+	if err != nil {
+		return nil, err
+	}
+	return resp.refund("INR"), nil
+}
+
+// GetRefundStatus is RefundStatus with the details of pg.Refund. It needs
+// req.GatewayOrderID and req.RefundID, the refId. The result's RefundID is
+// Paytm's refundId and its MerchantRefundID the refId. Paytm does not report
+// the refund speed, so Speed is left empty; ARN is the bank's RRN.
+func (a *Adapter) GetRefundStatus(ctx context.Context, req pg.RefundLookup) (*pg.Refund, error) {
+	if req.GatewayOrderID == "" || req.RefundID == "" {
+		return nil, fmt.Errorf("paytm: refund status: %w: GatewayOrderID and RefundID are required", pg.ErrInvalidRequest)
+	}
+	resp, err := a.refundStatus(ctx, req.GatewayOrderID, req.RefundID)
+	if err != nil {
+		return nil, err
+	}
+	r := resp.refund("INR")
+	out := &pg.Refund{
+		RefundID:         resp.Body.RefundID,
+		MerchantRefundID: resp.Body.RefID,
+		GatewayOrderID:   req.GatewayOrderID,
+		GatewayPaymentID: resp.Body.TxnID,
+		Amount:           r.Amount,
+		Currency:         r.Currency,
+		State:            refundState(r.Status),
+		Status:           r.Status,
+	}
+	for _, d := range resp.Body.RefundDetailInfoList {
+		if d.RRN != "" {
+			out.ARN = d.RRN
+			break
+		}
+	}
+	out.CreatedAt = parseTime(resp.Body.AcceptRefundTimestamp)
+	return out, nil
+}
+
+// refundState maps a Paytm refund status onto a pg.RefundState. Both the
+// TXN_SUCCESS and TXN_FAILURE of the status API and their unprefixed forms
+// are accepted.
+func refundState(status string) pg.RefundState {
+	switch status {
+	case "PENDING":
+		return pg.RefundStatePending
+	case "TXN_SUCCESS", "SUCCESS":
+		return pg.RefundStateProcessed
+	case "TXN_FAILURE", "FAILURE":
+		return pg.RefundStateFailed
+	}
+	return pg.RefundStateUnknown
+}
+
+// ist is the time zone of Paytm's timestamps.
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+// parseTime parses a Paytm timestamp such as "2024-03-01 12:31:47.0", which
+// is in IST, returning the zero time if it is malformed.
+func parseTime(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, ist)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// refundListPageSize is the number of refunds requested per page of the
+// refund list API.
+const refundListPageSize = 50
+
+// refundListResponse is the response of the /merchant-passbook/api/v1/refundList API.
+type refundListResponse struct {
+	Body struct {
+		ResultInfo struct {
+			ResultStatus string `json:"resultStatus"`
+			ResultCode   string `json:"resultCode"`
+			ResultMsg    string `json:"resultMsg"`
+		} `json:"resultInfo"`
+		Orders []struct {
+			OrderID         string `json:"orderId"`
+			MerchantOrderID string `json:"merchantOrderId"`
+			TxnID           string `json:"txnId"`
+			RefID           string `json:"refId"`
+			RefundID        string `json:"refundId"`
+			Amount          string `json:"refundAmount"`
+			Status          string `json:"refundStatus"`
+			RequestedAt     string `json:"merchantRefundRequestTimestamp"`
+		} `json:"orders"`
+	} `json:"body"`
+}
+
+// ListRefunds returns the refunds of req.GatewayOrderID, oldest first, from
+// Paytm's refund list API. That API lists every refund of the merchant
+// between two dates, so the order's transaction date is fetched first and
+// the refunds made since then are filtered by order ID. Orders without a
+// transaction have no refunds. The refunds lack the ARN; GetRefundStatus
+// with a refund's MerchantRefundID returns it.
+func (a *Adapter) ListRefunds(ctx context.Context, req pg.RefundLookup) ([]pg.Refund, error) {
+	if req.GatewayOrderID == "" {
+		return nil, fmt.Errorf("paytm: list refunds: %w: GatewayOrderID is required", pg.ErrInvalidRequest)
+	}
+	statusResp, err := a.orderStatus(ctx, req.GatewayOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if statusResp.Body.TxnID == "" {
+		return nil, nil
+	}
+	since := parseTime(statusResp.Body.TxnDate)
+	if since.IsZero() {
+		return nil, fmt.Errorf("paytm: list refunds: malformed transaction date %q", statusResp.Body.TxnDate)
+	}
+
+	var refunds []pg.Refund
+	for page := 1; ; page++ {
+		body := struct {
+			MID       string `json:"mid"`
+			IsSort    bool   `json:"isSort"`
+			StartDate string `json:"startDate"`
+			EndDate   string `json:"endDate"`
+			PageSize  int    `json:"pageSize"`
+			PageNum   int    `json:"pageNum"`
+		}{
+			MID:       a.cfg.MID,
+			IsSort:    true,
+			StartDate: since.Format(time.RFC3339),
+			EndDate:   time.Now().In(ist).Format(time.RFC3339),
+			PageSize:  refundListPageSize,
+			PageNum:   page,
+		}
+		var resp refundListResponse
+		if err := a.signedPost(ctx, "list refunds", "/merchant-passbook/api/v1/refundList", body, &resp); err != nil {
+			return nil, err
+		}
+		info := resp.Body.ResultInfo
+		if _, ok := resultCodeKinds[info.ResultCode]; ok || info.ResultStatus == "FAILURE" {
+			return nil, fmt.Errorf("paytm: list refunds failed: %w", resultError(info.ResultCode, info.ResultMsg, nil))
+		}
+		for _, o := range resp.Body.Orders {
+			if o.OrderID == "" {
+				o.OrderID = o.MerchantOrderID
+			}
+			if o.OrderID != req.GatewayOrderID {
+				continue
+			}
+			if o.TxnID == "" {
+				o.TxnID = statusResp.Body.TxnID
+			}
+			r := pg.Refund{
+				RefundID:         o.RefundID,
+				MerchantRefundID: o.RefID,
+				GatewayOrderID:   o.OrderID,
+				GatewayPaymentID: o.TxnID,
+				Currency:         "INR",
+				State:            refundState(o.Status),
+				Status:           o.Status,
+				CreatedAt:        parseTime(o.RequestedAt),
+			}
+			if m, err := pg.ParseMoney(o.Amount, "INR"); err == nil {
+				r.Amount = m.Amount
+			}
+			refunds = append(refunds, r)
+		}
+		if len(resp.Body.Orders) < refundListPageSize {
+			break
+		}
+	}
+	sort.SliceStable(refunds, func(i, j int) bool { return refunds[i].CreatedAt.Before(refunds[j].CreatedAt) })
+	return refunds, nil
+}
+
+func (a *Adapter) refundStatus(ctx context.Context, gatewayOrderID, refundID string) (*refundResponse, error) {
+	body := struct {
+		MID     string `json:"mid"`
+		OrderID string `json:"orderId"`
+		RefID   string `json:"refId"`
+	}{MID: a.cfg.MID, OrderID: gatewayOrderID, RefID: refundID}
+	var resp refundResponse
+	if err := a.signedPost(ctx, "refund status", "/v2/refund/status", body, &resp); err != nil {
+		return nil, err
+	}
+	info := resp.Body.ResultInfo
+	if _, ok := resultCodeKinds[info.ResultCode]; ok {
+		return nil, fmt.Errorf("paytm: refund status failed: %w", resultError(info.ResultCode, info.ResultMsg, nil))
+	}
+	return &resp, nil
+}
+
+// signedPost sends body to path with a head signed by the merchant key and
+// decodes the response into out. op names the call in errors.
+func (a *Adapter) signedPost(ctx context.Context, op, path string, body, out interface{}) error {
+	key, err := a.merchantKey(ctx)
+	if err != nil {
+		return err
+	}
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("paytm: marshal %s body: %w", op, err)
+	}
+	payloadJSON, err := json.Marshal(map[string]interface{}{
+		"body": json.RawMessage(bodyJSON),
+		"head": map[string]string{
+			"signature": computeSignature(string(bodyJSON), key),
+			"tokenType": "AES",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("paytm: marshal %s request: %w", op, err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL()+path, bytes.NewReader(payloadJSON))
+	if err != nil {
+		return fmt.Errorf("paytm: create %s request: %w", op, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("paytm: %s HTTP request: %w", op, transportError(err))
+	}
+	defer resp.Body.Close()
+	if err := httpError(resp.StatusCode); err != nil {
+		return fmt.Errorf("paytm: %s failed: %w", op, err)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("paytm: decode %s response: %w", op, err)
+	}
+	return nil
 }
 
 // VerifyWebhookSignature verifies the X-Paytm-Signature header.
 func (a *Adapter) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, ok := a.VerifyWebhook(payload, headers)
+	return ok
+}
+
+// VerifyWebhook verifies the X-Paytm-Signature header against WebhookSecret
+// and every unexpired entry of WebhookSecrets, reporting which one matched.
+func (a *Adapter) VerifyWebhook(payload []byte, headers map[string]string) (string, bool) {
 	sig := headers["x-paytm-signature"]
-	if sig == "" || a.cfg.WebhookSecret == "" {
-		return false
+	if sig == "" {
+		return "", false
+	}
+	primary := pg.LookupWebhookSecret(context.Background(), a.cfg.Secrets, pg.SecretPaytmWebhookSecret, a.cfg.WebhookSecret, a.cfg.OnSecretError)
+	for _, secret := range pg.ActiveWebhookSecrets(primary, a.cfg.WebhookSecrets, time.Now()) {
+		if hmac.Equal([]byte(sig), []byte(computeSignature(string(payload), secret.Value))) {
+			return secret.ID, true
+		}
 	}
-	expected := computeSignature(string(payload), a.cfg.WebhookSecret)
-	return sig == expected
+	return "", false
 }
 
 // ParseWebhookEvent parses a Paytm webhook payload into a normalised WebhookEvent.
@@ -293,13 +811,18 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.WebhookEvent, error) {
 	evt := &pg.WebhookEvent{Type: pg.WebhookEventUnknown, Raw: raw}
 
 	// Paytm webhook body contains a "body" key with transaction details
-	if body, ok := raw["body"].(map[string]interface{}); ok {
+	body, ok := raw["body"].(map[string]interface{})
+	if ok && (body["refId"] != nil || body["refundId"] != nil) {
+		parseRefundWebhook(evt, body)
+		return evt, nil
+	}
+	if ok {
 		if status, ok := body["txnStatus"].(string); ok {
 			switch status {
 			case "TXN_SUCCESS":
-				evt.Type = pg.WebhookEventPaymentSuccess
+				evt.Type, evt.State = pg.WebhookEventPaymentSuccess, pg.PaymentStateCaptured
 			case "TXN_FAILURE":
-				evt.Type = pg.WebhookEventPaymentFailed
+				evt.Type, evt.State = pg.WebhookEventPaymentFailed, pg.PaymentStateFailed
 			}
 		}
 		if ordID, ok := body["orderId"].(string); ok {
@@ -308,11 +831,49 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.WebhookEvent, error) {
 		if txnID, ok := body["txnId"].(string); ok {
 			evt.GatewayPaymentID = txnID
 		}
+		if amount, ok := body["txnAmount"].(string); ok {
+			currency, _ := body["currency"].(string)
+			if currency == "" {
+				currency = "INR"
+			}
+			if m, err := pg.ParseMoney(amount, currency); err == nil {
+				evt.Amount, evt.Currency = m.Amount, m.Currency
+			}
+		}
 	}
 
 	return evt, nil
 }
 
+// parseRefundWebhook fills evt from the body of a refund notification. The
+// refund ID is the refId, as returned by InitiateRefund.
+func parseRefundWebhook(evt *pg.WebhookEvent, body map[string]interface{}) {
+	status, _ := body["status"].(string)
+	if status == "" {
+		status, _ = body["txnStatus"].(string)
+	}
+	switch status {
+	case "TXN_SUCCESS":
+		evt.Type = pg.WebhookEventRefundSuccess
+	case "TXN_FAILURE":
+		evt.Type = pg.WebhookEventRefundFailed
+	}
+	evt.GatewayOrderID, _ = body["orderId"].(string)
+	evt.GatewayPaymentID, _ = body["txnId"].(string)
+	evt.RefundID, _ = body["refId"].(string)
+	if evt.RefundID == "" {
+		evt.RefundID, _ = body["refundId"].(string)
+	}
+	if amount, ok := body["refundAmount"].(string); ok {
+		if m, err := pg.ParseMoney(amount, "INR"); err == nil {
+			evt.Amount, evt.Currency = m.Amount, m.Currency
+		}
+	}
+	if reason, ok := body["resultMsg"].(string); ok && evt.Type == pg.WebhookEventRefundFailed {
+		evt.FailureReason = reason
+	}
+}
+
 // ClientCredentials returns the Paytm credentials the mobile SDK needs.
 // The txn_token is not here — it comes from CreateOrderResponse.Extra and is
 // merged into client_payload by the payment usecase.
@@ -325,6 +886,53 @@ func (a *Adapter) ClientCredentials() map[string]interface{} {
 
 // ─── helpers ─────────────────────────────────────────────────────────────────
 
+// baseURL returns the Paytm API host for the configured environment.
+func (a *Adapter) baseURL() string {
+	if a.cfg.Production {
+		return productionBase
+	}
+	return stagingBase
+}
+
+// resultError converts a failed resultInfo into a *pg.GatewayError. Codes
+// missing from resultCodeKinds are classified as fallback.
+func resultError(code, msg string, fallback error) error {
+	kind, ok := resultCodeKinds[code]
+	if !ok {
+		kind = fallback
+	}
+	return &pg.GatewayError{
+		Gateway:   "paytm",
+		Kind:      kind,
+		Code:      code,
+		Message:   msg,
+		Retriable: code == resultCodeSystemError,
+	}
+}
+
+// httpError classifies an HTTP status that means the request was not
+// processed, or returns nil for any other status.
+func httpError(status int) error {
+	err := &pg.GatewayError{Gateway: "paytm", Message: fmt.Sprintf("HTTP %d", status)}
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		err.Kind = pg.ErrUnauthenticated
+	case status == http.StatusTooManyRequests:
+		err.Kind, err.Retriable = pg.ErrRateLimited, true
+	case status >= http.StatusInternalServerError:
+		err.Kind, err.Retriable = pg.ErrGatewayUnavailable, true
+	default:
+		return nil
+	}
+	return err
+}
+
+// transportError wraps an error from the HTTP client, which means Paytm
+// could not be reached.
+func transportError(err error) error {
+	return &pg.GatewayError{Gateway: "paytm", Kind: pg.ErrGatewayUnavailable, Retriable: true, Err: err}
+}
+
 // computeSignature computes HMAC-SHA256 of data using key, base64-encoded.
 func computeSignature(data, key string) string {
 	mac := hmac.New(sha256.New, []byte(key))
@@ -2,28 +2,59 @@
 package paytm_payout
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	pg "github.com/KriaaCompany/pg-switcher-sdk"
 )
 
-// Config holds Paytm Payouts credentials
-type Config struct {
-	MID         string
-	MerchantKey string
-	Production  bool
+const (
+	productionBase = "https://dashboard.paytm.com"
+	stagingBase    = "https://staging-dashboard.paytm.com"
+)
+
+// Config holds Paytm Payouts credentials. Only MID, MerchantKey, Production
+// and HTTPClient are used.
+type Config = pg.PaytmConfig
+
+func init() {
+	pg.RegisterPayoutDriver("paytm", func(cfg pg.Config) (pg.PayoutGateway, error) {
+		if cfg.Paytm.MID == "" {
+			return nil, pg.ErrNotConfigured
+		}
+		c := cfg.Paytm
+		if c.Secrets == nil {
+			c.Secrets = cfg.Secrets
+		}
+		if c.OnSecretError == nil {
+			c.OnSecretError = cfg.OnSecretError
+		}
+		return New(c), nil
+	})
 }
 
 // Adapter implements pg.PayoutGateway for Paytm Payouts
 type Adapter struct {
-	cfg Config
+	cfg    Config
+	client *http.Client
 }
 
 // New creates a new Paytm PayoutGateway adapter
 func New(cfg Config) *Adapter {
-	return &Adapter{cfg: cfg}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Adapter{cfg: cfg, client: client}
 }
 
 // Name returns the gateway identifier
@@ -34,33 +65,153 @@ func (a *Adapter) IsManual() bool { return false }
 
 // CreateContact is a no-op stub (Paytm Payouts doesn't require pre-registration)
 func (a *Adapter) CreateContact(_ context.Context, req pg.CreateContactRequest) (*pg.ContactResponse, error) {
-	return nil, fmt.Errorf("paytm_payout: CreateContact not yet implemented")
+	return nil, fmt.Errorf("paytm_payout: CreateContact: %w", pg.ErrNotImplemented)
 }
 
 // UpdateContact is a no-op stub
 func (a *Adapter) UpdateContact(_ context.Context, contactID string, req pg.CreateContactRequest) (*pg.ContactResponse, error) {
-	return nil, fmt.Errorf("paytm_payout: UpdateContact not yet implemented")
+	return nil, fmt.Errorf("paytm_payout: UpdateContact: %w", pg.ErrNotImplemented)
 }
 
 // CreateFundAccount is a no-op stub
 func (a *Adapter) CreateFundAccount(_ context.Context, req pg.CreateFundAccountRequest) (*pg.FundAccountResponse, error) {
-	return nil, fmt.Errorf("paytm_payout: CreateFundAccount not yet implemented")
+	return nil, fmt.Errorf("paytm_payout: CreateFundAccount: %w", pg.ErrNotImplemented)
 }
 
+// SupportsCurrency reports whether Paytm can pay out in currency; only INR is
+// supported.
+func (a *Adapter) SupportsCurrency(currency string) bool { return currency == "INR" }
+
 // InitiatePayout initiates a Paytm payout
 func (a *Adapter) InitiatePayout(_ context.Context, req pg.InitiatePayoutRequest) (*pg.PayoutResponse, error) {
-	return nil, fmt.Errorf("paytm_payout: InitiatePayout not yet implemented — integrate Paytm Payouts API")
+	return nil, fmt.Errorf("paytm_payout: InitiatePayout: %w", pg.ErrNotImplemented)
 }
 
 // GetPayoutStatus queries a Paytm payout's status
 func (a *Adapter) GetPayoutStatus(_ context.Context, gatewayPayoutID string) (*pg.PayoutStatusResponse, error) {
-	return nil, fmt.Errorf("paytm_payout: GetPayoutStatus not yet implemented")
+	return nil, fmt.Errorf("paytm_payout: GetPayoutStatus: %w", pg.ErrNotImplemented)
+}
+
+// healthProbeOrderID is a disbursal order ID that never exists
+const healthProbeOrderID = "pg-switcher-health-probe"
+
+// HealthCheck queries the status of a non-existent disbursal. Paytm checks the
+// x-mid and x-checksum headers first, so a 401/403, or a FAILURE body blaming
+// the checksum or MID, means the credentials are wrong, while an "order not
+// found" response means they work.
+func (a *Adapter) HealthCheck(ctx context.Context) pg.HealthCheckResult {
+	start := time.Now()
+	err := a.probe(ctx)
+	res := pg.HealthCheckResult{Gateway: a.Name(), Latency: time.Since(start)}
+	if err != nil {
+		res.Err = fmt.Errorf("paytm_payout: health check failed: %w", err)
+		return res
+	}
+	res.Healthy = true
+	return res
+}
+
+func (a *Adapter) probe(ctx context.Context) error {
+	key, err := pg.LookupSecret(ctx, a.cfg.Secrets, pg.SecretPaytmMerchantKey, a.cfg.MerchantKey)
+	if err != nil {
+		return err
+	}
+	if a.cfg.MID == "" || key == "" {
+		return fmt.Errorf("%w: MID and MerchantKey are required", pg.ErrUnauthenticated)
+	}
+	body, err := json.Marshal(map[string]string{"orderId": healthProbeOrderID})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	base := stagingBase
+	if a.cfg.Production {
+		base = productionBase
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/bpay/api/v1/disburse/order/query", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-mid", a.cfg.MID)
+	httpReq.Header.Set("x-checksum", computeSignature(string(body), key))
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%w: %w", pg.ErrGatewayUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: HTTP %d", pg.ErrUnauthenticated, resp.StatusCode)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: HTTP %d", pg.ErrGatewayUnavailable, resp.StatusCode)
+	}
+
+	var result probeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%w: decode response: %w", pg.ErrGatewayUnavailable, err)
+	}
+	return result.err()
+}
+
+// probeResponse is the part of a disbursal status response the health check reads.
+type probeResponse struct {
+	Status        string `json:"status"` // "SUCCESS", "PENDING" or "FAILURE"
+	StatusCode    string `json:"statusCode"`
+	StatusMessage string `json:"statusMessage"`
+}
+
+// err classifies a FAILURE response. Paytm reports bad credentials in the
+// body with HTTP 200, so failures are told apart by their message; any other
+// failure, such as the probe order not existing, means the credentials work.
+func (r probeResponse) err() error {
+	if r.Status != "FAILURE" {
+		return nil
+	}
+	msg := strings.ToLower(r.StatusMessage)
+	words := strings.FieldsFunc(msg, func(c rune) bool { return c < 'a' || c > 'z' })
+	switch {
+	case strings.Contains(msg, "checksum"), slices.Contains(words, "mid"), strings.Contains(msg, "merchant id"),
+		strings.Contains(msg, "unauthori"), strings.Contains(msg, "authentication"):
+		return fmt.Errorf("%w: %s (code %s)", pg.ErrUnauthenticated, r.StatusMessage, r.StatusCode)
+	case strings.Contains(msg, "system error"), strings.Contains(msg, "try again"):
+		return fmt.Errorf("%w: %s (code %s)", pg.ErrGatewayUnavailable, r.StatusMessage, r.StatusCode)
+	}
+	return nil
 }
 
 // VerifyWebhookSignature verifies the X-Paytm-Signature header
 func (a *Adapter) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, ok := a.VerifyWebhook(payload, headers)
+	return ok
+}
+
+// VerifyWebhook verifies the X-Paytm-Signature header against WebhookSecret
+// and every unexpired entry of WebhookSecrets, reporting which one matched.
+// When neither is configured, the merchant key (ID "merchant_key") is used.
+func (a *Adapter) VerifyWebhook(payload []byte, headers map[string]string) (string, bool) {
 	sig := headers["x-paytm-signature"]
-	return sig != "" && a.cfg.MerchantKey != ""
+	if sig == "" {
+		return "", false
+	}
+	ctx := context.Background()
+	primary := pg.LookupWebhookSecret(ctx, a.cfg.Secrets, pg.SecretPaytmWebhookSecret, a.cfg.WebhookSecret, a.cfg.OnSecretError)
+	secrets := pg.ActiveWebhookSecrets(primary, a.cfg.WebhookSecrets, time.Now())
+	if len(secrets) == 0 {
+		key := pg.LookupWebhookSecret(ctx, a.cfg.Secrets, pg.SecretPaytmMerchantKey, a.cfg.MerchantKey, a.cfg.OnSecretError)
+		if key == "" {
+			return "", false
+		}
+		secrets = []pg.WebhookSecret{{ID: "merchant_key", Value: key}}
+	}
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(sig), []byte(computeSignature(string(payload), secret.Value))) {
+			return secret.ID, true
+		}
+	}
+	return "", false
 }
 
 // ParseWebhookEvent parses a Paytm payout webhook
@@ -71,3 +222,10 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.PayoutWebhookEvent, err
 	}
 	return &pg.PayoutWebhookEvent{Type: pg.PayoutWebhookEventUnknown}, nil
 }
+
+// computeSignature computes HMAC-SHA256 of data using key, base64-encoded
+func computeSignature(data, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
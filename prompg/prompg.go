@@ -0,0 +1,138 @@
+// Package prompg exports Prometheus metrics for pg gateways and switchers.
+//
+// It lives in its own module so that the core SDK does not depend on the
+// Prometheus client.
+package prompg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	pg "github.com/KriaaCompany/pg-switcher-sdk"
+)
+
+// Metrics holds the collectors. Create it once per registry with New.
+type Metrics struct {
+	calls    *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	webhooks *prometheus.CounterVec
+	active   *prometheus.GaugeVec
+
+	mu     sync.Mutex
+	picked map[string]string // switcher -> gateway last reported as active
+}
+
+// Option configures Metrics
+type Option func(*options)
+
+type options struct {
+	namespace string
+	buckets   []float64
+}
+
+// WithNamespace prefixes every metric name (default "pg")
+func WithNamespace(ns string) Option {
+	return func(o *options) { o.namespace = ns }
+}
+
+// WithBuckets sets the latency histogram buckets in seconds
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) { o.buckets = buckets }
+}
+
+// New creates the collectors and registers them with reg.
+//
+// Exported metrics:
+//   - <ns>_gateway_calls_total{gateway,operation,outcome}: outcome is "success"
+//     or the pg.ErrorClass of the error
+//   - <ns>_gateway_call_duration_seconds{gateway,operation}
+//   - <ns>_webhook_verifications_total{gateway,result}: result is "pass" or "fail"
+//   - <ns>_switcher_active_gateway{switcher,gateway}: 1 for the gateway the
+//     switcher's resolver last picked, 0 for the others it has picked before
+func New(reg prometheus.Registerer, opts ...Option) (*Metrics, error) {
+	o := options{namespace: "pg", buckets: []float64{.05, .1, .25, .5, 1, 2, 4, 8, 15, 30}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m := &Metrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "gateway_calls_total",
+			Help:      "Gateway calls by gateway, operation and outcome.",
+		}, []string{"gateway", "operation", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "gateway_call_duration_seconds",
+			Help:      "Gateway call latency by gateway and operation.",
+			Buckets:   o.buckets,
+		}, []string{"gateway", "operation"}),
+		webhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "webhook_verifications_total",
+			Help:      "Webhook signature checks by gateway and result.",
+		}, []string{"gateway", "result"}),
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace,
+			Name:      "switcher_active_gateway",
+			Help:      "1 for the gateway each switcher's resolver currently picks.",
+		}, []string{"switcher", "gateway"}),
+		picked: make(map[string]string),
+	}
+	for _, c := range []prometheus.Collector{m.calls, m.latency, m.webhooks, m.active} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Interceptor returns a pg.Interceptor that records call counts, latencies
+// and webhook verification results. Use it with pg.WrapPaymentGateway,
+// pg.WrapPayoutGateway or pg.WithInterceptors.
+//
+// A switcher checks a webhook against every registered gateway, so each
+// webhook also counts as a "fail" for the gateways that did not send it.
+// Watch each gateway's pass rate rather than its raw fail count.
+func (m *Metrics) Interceptor() pg.Interceptor {
+	return func(ctx context.Context, inv *pg.Invocation, next pg.Handler) (interface{}, error) {
+		start := time.Now()
+		resp, err := next(ctx, inv.Request)
+		m.latency.WithLabelValues(inv.Gateway, inv.Operation).Observe(time.Since(start).Seconds())
+
+		outcome := "success"
+		if err != nil {
+			outcome = pg.ErrorClass(err)
+		}
+		m.calls.WithLabelValues(inv.Gateway, inv.Operation, outcome).Inc()
+
+		if inv.Operation == pg.OpVerifyWebhookSignature {
+			result := "fail"
+			if ok, _ := resp.(bool); ok {
+				result = "pass"
+			}
+			m.webhooks.WithLabelValues(inv.Gateway, result).Inc()
+		}
+		return resp, err
+	}
+}
+
+// ResolveObserver returns a function for pg.WithResolveObserver that keeps
+// the active gateway gauge of the named switcher (e.g. "payment") current.
+func (m *Metrics) ResolveObserver(switcher string) func(ctx context.Context, gateway string) {
+	return func(_ context.Context, gateway string) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		prev, seen := m.picked[switcher]
+		if seen && prev == gateway {
+			return
+		}
+		if seen {
+			m.active.WithLabelValues(switcher, prev).Set(0)
+		}
+		m.active.WithLabelValues(switcher, gateway).Set(1)
+		m.picked[switcher] = gateway
+	}
+}
@@ -0,0 +1,193 @@
+package prompg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	pg "github.com/KriaaCompany/pg-switcher-sdk"
+)
+
+// fakeGateway answers CreateOrder, fails InitiateRefund with err and accepts
+// webhooks signed "ok".
+type fakeGateway struct {
+	pg.PaymentGateway
+	name string
+	err  error
+}
+
+func (g fakeGateway) Name() string { return g.name }
+
+func (fakeGateway) CreateOrder(context.Context, pg.CreateOrderRequest) (*pg.CreateOrderResponse, error) {
+	return &pg.CreateOrderResponse{GatewayOrderID: "order_1"}, nil
+}
+
+func (g fakeGateway) InitiateRefund(context.Context, pg.RefundRequest) (*pg.RefundResponse, error) {
+	return nil, g.err
+}
+
+func (fakeGateway) VerifyWebhookSignature(_ []byte, headers map[string]string) bool {
+	return headers["X-Signature"] == "ok"
+}
+
+func TestInterceptorMetrics(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		call       func(gw pg.PaymentGateway)
+		wantCalls  map[[3]string]float64 // gateway, operation, outcome
+		wantHooks  map[[2]string]float64 // gateway, result
+		wantTimed  map[[2]string]uint64  // gateway, operation -> observations
+		wantSeries int                   // number of call series
+	}{
+		{
+			name: "successful calls",
+			call: func(gw pg.PaymentGateway) {
+				gw.CreateOrder(ctx, pg.CreateOrderRequest{})
+				gw.CreateOrder(ctx, pg.CreateOrderRequest{})
+			},
+			wantCalls:  map[[3]string]float64{{"fake", pg.OpCreateOrder, "success"}: 2},
+			wantTimed:  map[[2]string]uint64{{"fake", pg.OpCreateOrder}: 2},
+			wantSeries: 1,
+		},
+		{
+			name: "failures are labelled with the error class",
+			call: func(gw pg.PaymentGateway) {
+				gw.InitiateRefund(ctx, pg.RefundRequest{})
+				gw.CreateOrder(ctx, pg.CreateOrderRequest{})
+			},
+			wantCalls: map[[3]string]float64{
+				{"fake", pg.OpInitiateRefund, "invalid_request"}: 1,
+				{"fake", pg.OpCreateOrder, "success"}:            1,
+			},
+			wantTimed:  map[[2]string]uint64{{"fake", pg.OpInitiateRefund}: 1, {"fake", pg.OpCreateOrder}: 1},
+			wantSeries: 2,
+		},
+		{
+			name: "webhook verifications",
+			call: func(gw pg.PaymentGateway) {
+				gw.VerifyWebhookSignature(nil, map[string]string{"X-Signature": "ok"})
+				gw.VerifyWebhookSignature(nil, map[string]string{"X-Signature": "forged"})
+				gw.VerifyWebhookSignature(nil, map[string]string{"X-Signature": "forged"})
+			},
+			wantCalls:  map[[3]string]float64{{"fake", pg.OpVerifyWebhookSignature, "success"}: 3},
+			wantHooks:  map[[2]string]float64{{"fake", "pass"}: 1, {"fake", "fail"}: 2},
+			wantTimed:  map[[2]string]uint64{{"fake", pg.OpVerifyWebhookSignature}: 3},
+			wantSeries: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(prometheus.NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.call(pg.WrapPaymentGateway(fakeGateway{name: "fake", err: pg.ErrInvalidRequest}, m.Interceptor()))
+
+			if n := testutil.CollectAndCount(m.calls); n != tt.wantSeries {
+				t.Errorf("%d call series, want %d", n, tt.wantSeries)
+			}
+			for l, want := range tt.wantCalls {
+				if got := testutil.ToFloat64(m.calls.WithLabelValues(l[0], l[1], l[2])); got != want {
+					t.Errorf("calls%v = %v, want %v", l, got, want)
+				}
+			}
+			if n := testutil.CollectAndCount(m.webhooks); n != len(tt.wantHooks) {
+				t.Errorf("%d webhook series, want %d", n, len(tt.wantHooks))
+			}
+			for l, want := range tt.wantHooks {
+				if got := testutil.ToFloat64(m.webhooks.WithLabelValues(l[0], l[1])); got != want {
+					t.Errorf("webhooks%v = %v, want %v", l, got, want)
+				}
+			}
+			if n := testutil.CollectAndCount(m.latency); n != len(tt.wantTimed) {
+				t.Errorf("%d latency series, want %d", n, len(tt.wantTimed))
+			}
+			for l, want := range tt.wantTimed {
+				if got := histogram(t, m.latency.WithLabelValues(l[0], l[1]).(prometheus.Histogram)).GetSampleCount(); got != want {
+					t.Errorf("latency%v observations = %d, want %d", l, got, want)
+				}
+			}
+		})
+	}
+}
+
+// histogram returns the current state of h.
+func histogram(t *testing.T, h prometheus.Histogram) *dto.Histogram {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 1)
+	h.Collect(ch)
+	var out dto.Metric
+	if err := (<-ch).Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.GetHistogram()
+}
+
+func TestOptions(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(reg, WithNamespace("payments"), WithBuckets([]float64{1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := pg.WrapPaymentGateway(fakeGateway{name: "razorpay"}, m.Interceptor())
+	gw.CreateOrder(context.Background(), pg.CreateOrderRequest{})
+
+	for _, name := range []string{"payments_gateway_calls_total", "payments_gateway_call_duration_seconds"} {
+		if n, err := testutil.GatherAndCount(reg, name); err != nil || n != 1 {
+			t.Errorf("%s has %d series, %v; want 1", name, n, err)
+		}
+	}
+	h := histogram(t, m.latency.WithLabelValues("razorpay", pg.OpCreateOrder).(prometheus.Histogram))
+	if b := h.GetBucket(); len(b) != 1 || b[0].GetUpperBound() != 1 || b[0].GetCumulativeCount() != 1 {
+		t.Fatalf("buckets = %v, want one bucket at 1s holding the call", b)
+	}
+	if problems, err := testutil.GatherAndLint(reg); err != nil || len(problems) > 0 {
+		t.Fatalf("lint: %v, %v", problems, err)
+	}
+
+	if _, err := New(reg); err != nil {
+		t.Fatalf("New with the default namespace on the same registry: %v", err)
+	}
+	if _, err := New(reg, WithNamespace("payments")); err == nil {
+		t.Fatal("registering the same metrics twice succeeded")
+	}
+}
+
+func TestResolveObserver(t *testing.T) {
+	m, err := New(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateways := map[string]pg.PaymentGateway{"razorpay": fakeGateway{name: "razorpay"}, "paytm": fakeGateway{name: "paytm"}}
+	active := "razorpay"
+	s := pg.NewDynamicPaymentSwitcher(gateways, func(context.Context) (string, error) { return active, nil },
+		pg.WithResolveObserver(m.ResolveObserver("payment")))
+
+	steps := []struct {
+		active string
+		want   map[string]float64
+	}{
+		{"razorpay", map[string]float64{"razorpay": 1}},
+		{"razorpay", map[string]float64{"razorpay": 1}},
+		{"paytm", map[string]float64{"razorpay": 0, "paytm": 1}},
+		{"razorpay", map[string]float64{"razorpay": 1, "paytm": 0}},
+	}
+	for i, step := range steps {
+		active = step.active
+		if _, err := s.CreateOrder(context.Background(), pg.CreateOrderRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		if n := testutil.CollectAndCount(m.active); n != len(step.want) {
+			t.Fatalf("step %d: %d gauge series, want %d", i, n, len(step.want))
+		}
+		for gw, want := range step.want {
+			if got := testutil.ToFloat64(m.active.WithLabelValues("payment", gw)); got != want {
+				t.Fatalf("step %d: active{gateway=%q} = %v, want %v", i, gw, got, want)
+			}
+		}
+	}
+}
@@ -7,40 +7,99 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	rzp "github.com/razorpay/razorpay-go"
+	rzpErrors "github.com/razorpay/razorpay-go/errors"
 
 	pg "github.com/KriaaCompany/pg-switcher-sdk"
 )
 
 // Config holds Razorpay payment credentials
-type Config struct {
-	KeyID         string
-	KeySecret     string
-	WebhookSecret string
+type Config = pg.RazorpayConfig
+
+func init() {
+	pg.RegisterPaymentDriver("razorpay", func(cfg pg.Config) (pg.PaymentGateway, error) {
+		if cfg.Razorpay.KeyID == "" {
+			return nil, pg.ErrNotConfigured
+		}
+		c := cfg.Razorpay
+		if c.Secrets == nil {
+			c.Secrets = cfg.Secrets
+		}
+		if c.OnSecretError == nil {
+			c.OnSecretError = cfg.OnSecretError
+		}
+		return New(c), nil
+	})
 }
 
 // Adapter wraps the Razorpay SDK and implements pg.PaymentGateway
 type Adapter struct {
-	cfg    Config
-	client *rzp.Client
+	cfg Config
+
+	mu        sync.Mutex
+	client    *rzp.Client
+	clientKey [2]string // key ID and secret the client was built with
 }
 
-// New creates a new Razorpay PaymentGateway adapter
+// New creates a new Razorpay PaymentGateway adapter. When cfg.Secrets is set,
+// the key pair and webhook secret are looked up on every call, so rotated
+// keys take effect without creating a new adapter.
 func New(cfg Config) *Adapter {
-	return &Adapter{
-		cfg:    cfg,
-		client: rzp.NewClient(cfg.KeyID, cfg.KeySecret),
+	return &Adapter{cfg: cfg}
+}
+
+// api returns an SDK client for the current key pair, rebuilding it after a
+// rotation.
+func (a *Adapter) api(ctx context.Context) (*rzp.Client, error) {
+	keyID, err := a.keyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := pg.LookupSecret(ctx, a.cfg.Secrets, pg.SecretRazorpayKeySecret, a.cfg.KeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("razorpay: %w", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.client == nil || a.clientKey != [2]string{keyID, secret} {
+		a.client = rzp.NewClient(keyID, secret)
+		a.clientKey = [2]string{keyID, secret}
 	}
+	return a.client, nil
+}
+
+func (a *Adapter) keyID(ctx context.Context) (string, error) {
+	keyID, err := pg.LookupSecret(ctx, a.cfg.Secrets, pg.SecretRazorpayKeyID, a.cfg.KeyID)
+	if err != nil {
+		return "", fmt.Errorf("razorpay: %w", err)
+	}
+	return keyID, nil
 }
 
 // Name returns the gateway identifier
 func (a *Adapter) Name() string { return "razorpay" }
 
-// CreateOrder creates a Razorpay order
-func (a *Adapter) CreateOrder(_ context.Context, req pg.CreateOrderRequest) (*pg.CreateOrderResponse, error) {
+// CreateOrder creates a Razorpay order. AuthorizeOnly orders are created
+// with manual capture, so their payments stay authorized until
+// CapturePayment; Razorpay refunds them automatically if they are not
+// captured within the account's capture window.
+func (a *Adapter) CreateOrder(ctx context.Context, req pg.CreateOrderRequest) (*pg.CreateOrderResponse, error) {
+	if err := pg.CheckCurrency(a, req.Currency); err != nil {
+		return nil, fmt.Errorf("razorpay: %w", err)
+	}
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
 	notes := make(map[string]interface{})
 	for k, v := range req.Notes {
 		notes[k] = v
@@ -52,72 +111,422 @@ func (a *Adapter) CreateOrder(_ context.Context, req pg.CreateOrderRequest) (*pg
 		"receipt":  req.Receipt,
 		"notes":    notes,
 	}
+	// Overrides the account's automatic capture setting for this order
+	if req.AuthorizeOnly {
+		body["payment_capture"] = 0
+	}
 
-	result, err := a.client.Order.Create(body, nil)
+	result, err := client.Order.Create(body, nil)
 	if err != nil {
-		return nil, fmt.Errorf("razorpay: create order failed: %w", err)
+		return nil, fmt.Errorf("razorpay: create order failed: %w", classifyError(err))
 	}
 
 	id, _ := result["id"].(string)
 	return &pg.CreateOrderResponse{
 		GatewayOrderID: id,
+		Gateway:        a.Name(),
 		Amount:         req.Amount,
 		Currency:       req.Currency,
 	}, nil
 }
 
 // VerifyPayment verifies the Razorpay payment signature
-func (a *Adapter) VerifyPayment(_ context.Context, req pg.VerifyPaymentRequest) (bool, error) {
+func (a *Adapter) VerifyPayment(ctx context.Context, req pg.VerifyPaymentRequest) (bool, error) {
+	secret, err := pg.LookupSecret(ctx, a.cfg.Secrets, pg.SecretRazorpayKeySecret, a.cfg.KeySecret)
+	if err != nil {
+		return false, fmt.Errorf("razorpay: %w", err)
+	}
 	data := req.GatewayOrderID + "|" + req.GatewayPaymentID
-	h := hmac.New(sha256.New, []byte(a.cfg.KeySecret))
+	h := hmac.New(sha256.New, []byte(secret))
 	h.Write([]byte(data))
 	expected := hex.EncodeToString(h.Sum(nil))
 	return hmac.Equal([]byte(req.Signature), []byte(expected)), nil
 }
 
-// GetPaymentStatus queries a Razorpay order's status
-func (a *Adapter) GetPaymentStatus(_ context.Context, gatewayOrderID string) (*pg.PaymentStatus, error) {
-	result, err := a.client.Order.Fetch(gatewayOrderID, nil, nil)
+// GetPaymentStatus queries a Razorpay order's status. Once a payment has been
+// attempted, the order's payments are fetched too, so that State and
+// GatewayPaymentID reflect the most advanced of them (e.g. captured and then
+// partially refunded). Status is the order's status.
+func (a *Adapter) GetPaymentStatus(ctx context.Context, gatewayOrderID string) (*pg.PaymentStatus, error) {
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.Order.Fetch(gatewayOrderID, nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("razorpay: fetch order failed: %w", err)
+		return nil, fmt.Errorf("razorpay: fetch order failed: %w", classifyError(err))
 	}
 	status, _ := result["status"].(string)
-	return &pg.PaymentStatus{
+	out := &pg.PaymentStatus{
 		GatewayOrderID: gatewayOrderID,
+		State:          orderState(status),
 		Status:         status,
 		Paid:           status == "paid",
-	}, nil
+	}
+	if status == "created" || out.State == pg.PaymentStateUnknown {
+		return out, nil
+	}
+
+	payments, err := client.Order.Payments(gatewayOrderID, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("razorpay: fetch order payments failed: %w", classifyError(err))
+	}
+	items, _ := payments["items"].([]interface{})
+	best := 0
+	for _, item := range items {
+		p, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		state := paymentState(p)
+		if rank := paymentStateRank[state]; rank > best {
+			best = rank
+			out.State = state
+			out.GatewayPaymentID, _ = p["id"].(string)
+		}
+	}
+	return out, nil
 }
 
-// InitiateRefund creates a Razorpay refund
-func (a *Adapter) InitiateRefund(_ context.Context, req pg.RefundRequest) (*pg.RefundResponse, error) {
+// setRefundAmount copies the refund entity's amount into evt.
+func setRefundAmount(evt *pg.WebhookEvent, refundEntity map[string]interface{}) {
+	if v, ok := refundEntity["amount"].(float64); ok {
+		evt.Amount = int64(v)
+	}
+	if v, ok := refundEntity["currency"].(string); ok {
+		evt.Currency = v
+	}
+}
+
+// orderState maps a Razorpay order status onto a pg.PaymentState.
+func orderState(status string) pg.PaymentState {
+	switch status {
+	case "created":
+		return pg.PaymentStateCreated
+	case "attempted":
+		return pg.PaymentStatePending
+	case "paid":
+		return pg.PaymentStateCaptured
+	}
+	return pg.PaymentStateUnknown
+}
+
+// paymentState maps a Razorpay payment entity onto a pg.PaymentState.
+func paymentState(p map[string]interface{}) pg.PaymentState {
+	status, _ := p["status"].(string)
+	refundStatus, _ := p["refund_status"].(string)
+	switch {
+	case status == "refunded" || refundStatus == "full":
+		return pg.PaymentStateRefunded
+	case refundStatus == "partial":
+		return pg.PaymentStatePartiallyRefunded
+	}
+	switch status {
+	case "created":
+		return pg.PaymentStatePending
+	case "authorized":
+		return pg.PaymentStateAuthorized
+	case "captured":
+		return pg.PaymentStateCaptured
+	case "failed":
+		return pg.PaymentStateFailed
+	}
+	return pg.PaymentStateUnknown
+}
+
+// disputeState maps a payment.dispute.* event onto a pg.PaymentState. A won
+// dispute returns the payment to captured; every other dispute event,
+// including under_review and action_required, leaves it disputed.
+func disputeState(event string) pg.PaymentState {
+	if event == "payment.dispute.won" {
+		return pg.PaymentStateCaptured
+	}
+	return pg.PaymentStateDisputed
+}
+
+// paymentStateRank orders payment states by how far the payment got, so that
+// a captured payment wins over earlier failed attempts on the same order.
+var paymentStateRank = map[pg.PaymentState]int{
+	pg.PaymentStateUnknown:           0,
+	pg.PaymentStateFailed:            1,
+	pg.PaymentStatePending:           2,
+	pg.PaymentStateAuthorized:        3,
+	pg.PaymentStateCaptured:          4,
+	pg.PaymentStatePartiallyRefunded: 5,
+	pg.PaymentStateRefunded:          6,
+}
+
+// InitiateRefund creates a Razorpay refund of req.GatewayPaymentID. A zero
+// Amount refunds the remaining amount; RefundID is sent as the receipt.
+func (a *Adapter) InitiateRefund(ctx context.Context, req pg.RefundRequest) (*pg.RefundResponse, error) {
+	if req.GatewayPaymentID == "" {
+		return nil, fmt.Errorf("razorpay: refund: %w: GatewayPaymentID is required", pg.ErrInvalidRequest)
+	}
+	if req.Currency != "" {
+		if err := pg.CheckCurrency(a, req.Currency); err != nil {
+			return nil, fmt.Errorf("razorpay: %w", err)
+		}
+	}
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
 	notes := make(map[string]interface{})
 	for k, v := range req.Notes {
 		notes[k] = v
 	}
 	body := map[string]interface{}{
-		"amount": req.Amount,
-		"notes":  notes,
+		"notes": notes,
+	}
+	// Without an amount, Razorpay refunds whatever has not been refunded yet
+	if req.Amount > 0 {
+		body["amount"] = req.Amount
 	}
-	result, err := a.client.Payment.Refund(req.GatewayPaymentID, int(req.Amount), body, nil)
+	if req.RefundID != "" {
+		body["receipt"] = req.RefundID
+	}
+	result, err := client.Request.Post(fmt.Sprintf("/v1/payments/%s/refund", url.PathEscape(req.GatewayPaymentID)), body, nil)
 	if err != nil {
-		return nil, fmt.Errorf("razorpay: refund failed: %w", err)
+		return nil, fmt.Errorf("razorpay: refund failed: %w", classifyError(err))
 	}
 	id, _ := result["id"].(string)
 	status, _ := result["status"].(string)
-	return &pg.RefundResponse{RefundID: id, Amount: req.Amount, Status: status}, nil
+	resp := &pg.RefundResponse{RefundID: id, Amount: req.Amount, Currency: req.Currency, Status: status}
+	if v, ok := result["amount"].(float64); ok {
+		resp.Amount = int64(v)
+	}
+	if v, ok := result["currency"].(string); ok {
+		resp.Currency = v
+	}
+	return resp, nil
+}
+
+// GetRefundStatus fetches a Razorpay refund by req.RefundID, the rfnd_ ID
+// returned by InitiateRefund. GatewayPaymentID is optional and
+// GatewayOrderID is only copied into the result.
+func (a *Adapter) GetRefundStatus(ctx context.Context, req pg.RefundLookup) (*pg.Refund, error) {
+	if req.RefundID == "" {
+		return nil, fmt.Errorf("razorpay: refund status: %w: RefundID is required", pg.ErrInvalidRequest)
+	}
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if req.GatewayPaymentID != "" {
+		result, err = client.Payment.FetchRefund(req.GatewayPaymentID, req.RefundID, nil, nil)
+	} else {
+		result, err = client.Refund.Fetch(req.RefundID, nil, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("razorpay: fetch refund failed: %w", classifyError(err))
+	}
+	r := refund(result)
+	r.GatewayOrderID = req.GatewayOrderID
+	return &r, nil
+}
+
+// refundPageSize is the largest page Razorpay returns from its list APIs.
+const refundPageSize = 100
+
+// ListRefunds lists the refunds of req.GatewayPaymentID or, when that is
+// empty, of every payment of req.GatewayOrderID.
+func (a *Adapter) ListRefunds(ctx context.Context, req pg.RefundLookup) ([]pg.Refund, error) {
+	if req.GatewayPaymentID == "" && req.GatewayOrderID == "" {
+		return nil, fmt.Errorf("razorpay: list refunds: %w: GatewayPaymentID or GatewayOrderID is required", pg.ErrInvalidRequest)
+	}
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paymentIDs := []string{req.GatewayPaymentID}
+	if req.GatewayPaymentID == "" {
+		payments, err := client.Order.Payments(req.GatewayOrderID, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("razorpay: fetch order payments failed: %w", classifyError(err))
+		}
+		paymentIDs = paymentIDs[:0]
+		items, _ := payments["items"].([]interface{})
+		for _, item := range items {
+			if p, ok := item.(map[string]interface{}); ok {
+				if id, _ := p["id"].(string); id != "" {
+					paymentIDs = append(paymentIDs, id)
+				}
+			}
+		}
+	}
+
+	var out []pg.Refund
+	for _, paymentID := range paymentIDs {
+		for skip := 0; ; skip += refundPageSize {
+			page, err := client.Payment.FetchMultipleRefund(paymentID, map[string]interface{}{"count": refundPageSize, "skip": skip}, nil)
+			if err != nil {
+				return nil, fmt.Errorf("razorpay: list refunds failed: %w", classifyError(err))
+			}
+			items, _ := page["items"].([]interface{})
+			for _, item := range items {
+				if e, ok := item.(map[string]interface{}); ok {
+					r := refund(e)
+					r.GatewayOrderID = req.GatewayOrderID
+					out = append(out, r)
+				}
+			}
+			if len(items) < refundPageSize {
+				break
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// refund converts a Razorpay refund entity into a pg.Refund.
+func refund(e map[string]interface{}) pg.Refund {
+	r := pg.Refund{}
+	r.RefundID, _ = e["id"].(string)
+	r.MerchantRefundID, _ = e["receipt"].(string)
+	r.GatewayPaymentID, _ = e["payment_id"].(string)
+	r.Currency, _ = e["currency"].(string)
+	r.Status, _ = e["status"].(string)
+	if v, ok := e["amount"].(float64); ok {
+		r.Amount = int64(v)
+	}
+	if v, ok := e["created_at"].(float64); ok {
+		r.CreatedAt = time.Unix(int64(v), 0)
+	}
+	switch r.Status {
+	case "pending":
+		r.State = pg.RefundStatePending
+	case "processed":
+		r.State = pg.RefundStateProcessed
+	case "failed":
+		r.State = pg.RefundStateFailed
+	default:
+		r.State = pg.RefundStateUnknown
+	}
+	switch speed, _ := e["speed_processed"].(string); speed {
+	case "normal":
+		r.Speed = pg.RefundSpeedNormal
+	case "instant":
+		r.Speed = pg.RefundSpeedInstant
+	}
+	// acquirer_data carries whichever reference the payment method uses
+	acquirer, _ := e["acquirer_data"].(map[string]interface{})
+	for _, key := range []string{"arn", "rrn", "utr"} {
+		if ref, _ := acquirer[key].(string); ref != "" {
+			r.ARN = ref
+			break
+		}
+	}
+	return r
+}
+
+// CapturePayment captures an authorized Razorpay payment. Without
+// GatewayPaymentID, the authorized payment of GatewayOrderID is captured. A
+// zero Amount captures the full authorized amount; a smaller one is a partial
+// capture, which Razorpay rejects with pg.ErrInvalidRequest unless it is
+// enabled for the account.
+func (a *Adapter) CapturePayment(ctx context.Context, req pg.CaptureRequest) (*pg.PaymentStatus, error) {
+	if req.Amount < 0 {
+		return nil, fmt.Errorf("razorpay: capture: %w: negative amount", pg.ErrInvalidRequest)
+	}
+	if req.Currency != "" {
+		if err := pg.CheckCurrency(a, req.Currency); err != nil {
+			return nil, fmt.Errorf("razorpay: %w", err)
+		}
+	}
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payment, err := authorizedPayment(client, req.GatewayPaymentID, req.GatewayOrderID)
+	if err != nil {
+		return nil, err
+	}
+	paymentID, _ := payment["id"].(string)
+	amount, currency := req.Amount, req.Currency
+	if amount == 0 {
+		if v, ok := payment["amount"].(float64); ok {
+			amount = int64(v)
+		}
+	}
+	if currency == "" {
+		currency, _ = payment["currency"].(string)
+	}
+	result, err := client.Payment.Capture(paymentID, int(amount), map[string]interface{}{"currency": currency}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("razorpay: capture failed: %w", classifyError(err))
+	}
+	return paymentStatus(result), nil
+}
+
+// VoidPayment is not supported: Razorpay has no API to release an
+// authorization. Uncaptured payments are refunded automatically once the
+// account's capture window expires. It always fails with pg.ErrNotImplemented.
+func (a *Adapter) VoidPayment(context.Context, pg.VoidRequest) (*pg.PaymentStatus, error) {
+	return nil, fmt.Errorf("razorpay: VoidPayment: %w", pg.ErrNotImplemented)
+}
+
+// authorizedPayment fetches payment paymentID or, when that is empty, the
+// authorized payment of order orderID.
+func authorizedPayment(client *rzp.Client, paymentID, orderID string) (map[string]interface{}, error) {
+	if paymentID != "" {
+		payment, err := client.Payment.Fetch(paymentID, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("razorpay: fetch payment failed: %w", classifyError(err))
+		}
+		return payment, nil
+	}
+	if orderID == "" {
+		return nil, fmt.Errorf("razorpay: capture: %w: GatewayPaymentID or GatewayOrderID is required", pg.ErrInvalidRequest)
+	}
+	payments, err := client.Order.Payments(orderID, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("razorpay: fetch order payments failed: %w", classifyError(err))
+	}
+	items, _ := payments["items"].([]interface{})
+	for _, item := range items {
+		if p, ok := item.(map[string]interface{}); ok && p["status"] == "authorized" {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("razorpay: capture: %w: order %q has no authorized payment", pg.ErrInvalidRequest, orderID)
+}
+
+// paymentStatus converts a Razorpay payment entity into a pg.PaymentStatus.
+// Status is the payment's status.
+func paymentStatus(p map[string]interface{}) *pg.PaymentStatus {
+	out := &pg.PaymentStatus{State: paymentState(p)}
+	out.GatewayOrderID, _ = p["order_id"].(string)
+	out.GatewayPaymentID, _ = p["id"].(string)
+	out.Status, _ = p["status"].(string)
+	out.Paid = out.Status == "captured"
+	return out
 }
 
 // VerifyWebhookSignature verifies the X-Razorpay-Signature header
 func (a *Adapter) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, ok := a.VerifyWebhook(payload, headers)
+	return ok
+}
+
+// VerifyWebhook verifies the X-Razorpay-Signature header against WebhookSecret
+// and every unexpired entry of WebhookSecrets, reporting which one matched.
+func (a *Adapter) VerifyWebhook(payload []byte, headers map[string]string) (string, bool) {
 	sig := headers["x-razorpay-signature"]
 	if sig == "" {
-		return false
+		return "", false
 	}
-	h := hmac.New(sha256.New, []byte(a.cfg.WebhookSecret))
-	h.Write(payload)
-	expected := hex.EncodeToString(h.Sum(nil))
-	return hmac.Equal([]byte(sig), []byte(expected))
+	primary := pg.LookupWebhookSecret(context.Background(), a.cfg.Secrets, pg.SecretRazorpayWebhookSecret, a.cfg.WebhookSecret, a.cfg.OnSecretError)
+	for _, secret := range pg.ActiveWebhookSecrets(primary, a.cfg.WebhookSecrets, time.Now()) {
+		h := hmac.New(sha256.New, []byte(secret.Value))
+		h.Write(payload)
+		expected := hex.EncodeToString(h.Sum(nil))
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return secret.ID, true
+		}
+	}
+	return "", false
 }
 
 // ParseWebhookEvent parses a Razorpay webhook payload into a pg.WebhookEvent
@@ -156,14 +565,23 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.WebhookEvent, error) {
 	if v, ok := paymentEntity["error_description"].(string); ok {
 		evt.FailureReason = v
 	}
+	// Refund and dispute events replace the payment's amount with their own
+	if v, ok := paymentEntity["amount"].(float64); ok {
+		evt.Amount = int64(v)
+	}
+	if v, ok := paymentEntity["currency"].(string); ok {
+		evt.Currency = v
+	}
 
 	switch {
+	case envelope.Event == "payment.authorized":
+		evt.Type, evt.State = pg.WebhookEventPaymentAuthorized, pg.PaymentStateAuthorized
 	case envelope.Event == "payment.captured":
-		evt.Type = pg.WebhookEventPaymentSuccess
+		evt.Type, evt.State = pg.WebhookEventPaymentSuccess, pg.PaymentStateCaptured
 	case envelope.Event == "payment.failed":
-		evt.Type = pg.WebhookEventPaymentFailed
+		evt.Type, evt.State = pg.WebhookEventPaymentFailed, pg.PaymentStateFailed
 	case envelope.Event == "order.paid":
-		evt.Type = pg.WebhookEventOrderPaid
+		evt.Type, evt.State = pg.WebhookEventOrderPaid, pg.PaymentStateCaptured
 		// For order.paid, also get payment ID from nested payment entity
 		if v, ok := paymentEntity["id"].(string); ok {
 			evt.GatewayPaymentID = v
@@ -173,11 +591,13 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.WebhookEvent, error) {
 		if v, ok := refundEntity["payment_id"].(string); ok {
 			evt.GatewayPaymentID = v
 		}
+		setRefundAmount(evt, refundEntity)
 	case envelope.Event == "refund.failed":
 		evt.Type = pg.WebhookEventRefundFailed
 		if v, ok := refundEntity["payment_id"].(string); ok {
 			evt.GatewayPaymentID = v
 		}
+		setRefundAmount(evt, refundEntity)
 	case strings.HasPrefix(envelope.Event, "payment.dispute."):
 		switch envelope.Event {
 		case "payment.dispute.created":
@@ -191,6 +611,7 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.WebhookEvent, error) {
 		default:
 			evt.Type = pg.WebhookEventUnknown
 		}
+		evt.State = disputeState(envelope.Event)
 		if v, ok := disputeEntity["payment_id"].(string); ok {
 			evt.GatewayPaymentID = v
 		}
@@ -207,10 +628,32 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.WebhookEvent, error) {
 	return evt, nil
 }
 
-// ClientCredentials returns the Razorpay key_id for the mobile SDK
+// HealthCheck lists a single order to confirm the API keys are accepted
+func (a *Adapter) HealthCheck(ctx context.Context) pg.HealthCheckResult {
+	start := time.Now()
+	client, err := a.api(ctx)
+	if err != nil {
+		return pg.HealthCheckResult{Gateway: a.Name(), Err: err}
+	}
+	_, err = client.Order.All(map[string]interface{}{"count": 1}, nil)
+	res := pg.HealthCheckResult{Gateway: a.Name(), Latency: time.Since(start)}
+	if err != nil {
+		res.Err = fmt.Errorf("razorpay: health check failed: %w", classifyError(err))
+		return res
+	}
+	res.Healthy = true
+	return res
+}
+
+// ClientCredentials returns the Razorpay key_id for the mobile SDK. If the
+// secret provider fails, the configured key_id is returned.
 func (a *Adapter) ClientCredentials() map[string]interface{} {
+	keyID, err := a.keyID(context.Background())
+	if err != nil {
+		keyID = a.cfg.KeyID
+	}
 	return map[string]interface{}{
-		"key_id": a.cfg.KeyID,
+		"key_id": keyID,
 	}
 }
 
@@ -226,3 +669,39 @@ func extractEntity(payload map[string]interface{}, key string) map[string]interf
 	}
 	return entity
 }
+
+// classifyError maps razorpay-go SDK errors onto a *pg.GatewayError. Server,
+// gateway and network errors are retriable. The SDK drops the HTTP status, so
+// bad requests are classified by their description.
+func classifyError(err error) error {
+	var netErr net.Error
+	switch e := err.(type) {
+	case *rzpErrors.ServerError:
+		return &pg.GatewayError{Gateway: "razorpay", Kind: pg.ErrGatewayUnavailable, Code: "SERVER_ERROR", Message: e.Message, Retriable: true, Err: err}
+	case *rzpErrors.GatewayError:
+		return &pg.GatewayError{Gateway: "razorpay", Kind: pg.ErrGatewayUnavailable, Code: "GATEWAY_ERROR", Message: e.Message, Retriable: true, Err: err}
+	case *rzpErrors.BadRequestError:
+		kind, retriable := badRequestKind(e.Message)
+		return &pg.GatewayError{Gateway: "razorpay", Kind: kind, Code: "BAD_REQUEST_ERROR", Message: e.Message, Retriable: retriable, Err: err}
+	}
+	if errors.As(err, &netErr) {
+		return &pg.GatewayError{Gateway: "razorpay", Kind: pg.ErrGatewayUnavailable, Retriable: true, Err: err}
+	}
+	return err
+}
+
+// badRequestKind classifies a BAD_REQUEST_ERROR description.
+func badRequestKind(description string) (kind error, retriable bool) {
+	d := strings.ToLower(description)
+	switch {
+	case strings.Contains(d, "authentication failed"):
+		return pg.ErrUnauthenticated, false
+	case strings.Contains(d, "too many requests"), strings.Contains(d, "rate limit"):
+		return pg.ErrRateLimited, true
+	case strings.Contains(d, "does not exist"), strings.Contains(d, "not found"):
+		return pg.ErrNotFound, false
+	case strings.Contains(d, "not have enough balance"), strings.Contains(d, "insufficient balance"):
+		return pg.ErrInsufficientBalance, false
+	}
+	return pg.ErrInvalidRequest, false
+}
@@ -7,8 +7,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	rzp "github.com/razorpay/razorpay-go"
 	rzpErrors "github.com/razorpay/razorpay-go/errors"
@@ -17,25 +21,58 @@ import (
 )
 
 // Config holds RazorpayX payout credentials
-type Config struct {
-	KeyID         string
-	KeySecret     string
-	AccountNumber string
-	WebhookSecret string
+type Config = pg.RazorpayXConfig
+
+func init() {
+	pg.RegisterPayoutDriver("razorpayx", func(cfg pg.Config) (pg.PayoutGateway, error) {
+		if cfg.RazorpayX.KeyID == "" {
+			return nil, pg.ErrNotConfigured
+		}
+		c := cfg.RazorpayX
+		if c.Secrets == nil {
+			c.Secrets = cfg.Secrets
+		}
+		if c.OnSecretError == nil {
+			c.OnSecretError = cfg.OnSecretError
+		}
+		return New(c), nil
+	})
 }
 
 // Adapter wraps the RazorpayX API and implements pg.PayoutGateway
 type Adapter struct {
-	cfg    Config
-	client *rzp.Client
+	cfg Config
+
+	mu        sync.Mutex
+	client    *rzp.Client
+	clientKey [2]string // key ID and secret the client was built with
 }
 
-// New creates a new RazorpayX PayoutGateway adapter
+// New creates a new RazorpayX PayoutGateway adapter. When cfg.Secrets is set,
+// the key pair and webhook secret are looked up on every call, so rotated
+// keys take effect without creating a new adapter.
 func New(cfg Config) *Adapter {
-	return &Adapter{
-		cfg:    cfg,
-		client: rzp.NewClient(cfg.KeyID, cfg.KeySecret),
+	return &Adapter{cfg: cfg}
+}
+
+// api returns an SDK client for the current key pair, rebuilding it after a
+// rotation.
+func (a *Adapter) api(ctx context.Context) (*rzp.Client, error) {
+	keyID, err := pg.LookupSecret(ctx, a.cfg.Secrets, pg.SecretRazorpayXKeyID, a.cfg.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("razorpayx: %w", err)
 	}
+	secret, err := pg.LookupSecret(ctx, a.cfg.Secrets, pg.SecretRazorpayXKeySecret, a.cfg.KeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("razorpayx: %w", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.client == nil || a.clientKey != [2]string{keyID, secret} {
+		a.client = rzp.NewClient(keyID, secret)
+		a.clientKey = [2]string{keyID, secret}
+	}
+	return a.client, nil
 }
 
 // Name returns the gateway identifier
@@ -45,7 +82,11 @@ func (a *Adapter) Name() string { return "razorpayx" }
 func (a *Adapter) IsManual() bool { return false }
 
 // CreateContact creates a RazorpayX contact
-func (a *Adapter) CreateContact(_ context.Context, req pg.CreateContactRequest) (*pg.ContactResponse, error) {
+func (a *Adapter) CreateContact(ctx context.Context, req pg.CreateContactRequest) (*pg.ContactResponse, error) {
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
 	body := map[string]interface{}{
 		"name":         req.Name,
 		"type":         "vendor",
@@ -58,9 +99,9 @@ func (a *Adapter) CreateContact(_ context.Context, req pg.CreateContactRequest)
 		body["contact"] = req.Phone
 	}
 
-	result, err := a.client.Request.Post("/v1/contacts", body, map[string]string{"Content-Type": "application/json"})
+	result, err := client.Request.Post("/v1/contacts", body, map[string]string{"Content-Type": "application/json"})
 	if err != nil {
-		return nil, fmt.Errorf("razorpayx: create contact failed: %s", describeError(err))
+		return nil, fmt.Errorf("razorpayx: create contact failed: %w", classifyError(err))
 	}
 
 	id, ok := result["id"].(string)
@@ -71,7 +112,11 @@ func (a *Adapter) CreateContact(_ context.Context, req pg.CreateContactRequest)
 }
 
 // UpdateContact updates an existing RazorpayX contact
-func (a *Adapter) UpdateContact(_ context.Context, contactID string, req pg.CreateContactRequest) (*pg.ContactResponse, error) {
+func (a *Adapter) UpdateContact(ctx context.Context, contactID string, req pg.CreateContactRequest) (*pg.ContactResponse, error) {
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
 	body := map[string]interface{}{
 		"name": req.Name,
 	}
@@ -82,9 +127,9 @@ func (a *Adapter) UpdateContact(_ context.Context, contactID string, req pg.Crea
 		body["contact"] = req.Phone
 	}
 
-	result, err := a.client.Request.Patch(fmt.Sprintf("/v1/contacts/%s", contactID), body, map[string]string{"Content-Type": "application/json"})
+	result, err := client.Request.Patch(fmt.Sprintf("/v1/contacts/%s", contactID), body, map[string]string{"Content-Type": "application/json"})
 	if err != nil {
-		return nil, fmt.Errorf("razorpayx: update contact failed: %s", describeError(err))
+		return nil, fmt.Errorf("razorpayx: update contact failed: %w", classifyError(err))
 	}
 
 	id, ok := result["id"].(string)
@@ -95,7 +140,11 @@ func (a *Adapter) UpdateContact(_ context.Context, contactID string, req pg.Crea
 }
 
 // CreateFundAccount creates a RazorpayX fund account (UPI or bank)
-func (a *Adapter) CreateFundAccount(_ context.Context, req pg.CreateFundAccountRequest) (*pg.FundAccountResponse, error) {
+func (a *Adapter) CreateFundAccount(ctx context.Context, req pg.CreateFundAccountRequest) (*pg.FundAccountResponse, error) {
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var body map[string]interface{}
 
 	switch req.AccountType {
@@ -121,9 +170,9 @@ func (a *Adapter) CreateFundAccount(_ context.Context, req pg.CreateFundAccountR
 		return nil, fmt.Errorf("razorpayx: unknown account type %q", req.AccountType)
 	}
 
-	result, err := a.client.FundAccount.Create(body, nil)
+	result, err := client.FundAccount.Create(body, nil)
 	if err != nil {
-		return nil, fmt.Errorf("razorpayx: create fund account failed: %s", describeError(err))
+		return nil, fmt.Errorf("razorpayx: create fund account failed: %w", classifyError(err))
 	}
 
 	id, ok := result["id"].(string)
@@ -133,8 +182,19 @@ func (a *Adapter) CreateFundAccount(_ context.Context, req pg.CreateFundAccountR
 	return &pg.FundAccountResponse{FundAccountID: id}, nil
 }
 
+// SupportsCurrency reports whether RazorpayX can pay out in currency; only
+// INR is supported.
+func (a *Adapter) SupportsCurrency(currency string) bool { return currency == "INR" }
+
 // InitiatePayout creates a RazorpayX payout
-func (a *Adapter) InitiatePayout(_ context.Context, req pg.InitiatePayoutRequest) (*pg.PayoutResponse, error) {
+func (a *Adapter) InitiatePayout(ctx context.Context, req pg.InitiatePayoutRequest) (*pg.PayoutResponse, error) {
+	if err := pg.CheckCurrency(a, req.Currency); err != nil {
+		return nil, fmt.Errorf("razorpayx: %w", err)
+	}
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
 	body := map[string]interface{}{
 		"account_number":       a.cfg.AccountNumber,
 		"fund_account_id":      req.FundAccountID,
@@ -152,9 +212,9 @@ func (a *Adapter) InitiatePayout(_ context.Context, req pg.InitiatePayoutRequest
 		"X-Payout-Idempotency": req.ReferenceID,
 	}
 
-	result, err := a.client.Request.Post("/v1/payouts", body, extraHeaders)
+	result, err := client.Request.Post("/v1/payouts", body, extraHeaders)
 	if err != nil {
-		return nil, fmt.Errorf("razorpayx: create payout failed: %s", describeError(err))
+		return nil, fmt.Errorf("razorpayx: create payout failed: %w", classifyError(err))
 	}
 
 	id, ok := result["id"].(string)
@@ -162,38 +222,85 @@ func (a *Adapter) InitiatePayout(_ context.Context, req pg.InitiatePayoutRequest
 		return nil, fmt.Errorf("razorpayx: payout response missing id")
 	}
 	status, _ := result["status"].(string)
-	return &pg.PayoutResponse{GatewayPayoutID: id, Status: status}, nil
+	return &pg.PayoutResponse{GatewayPayoutID: id, State: payoutState(status), Status: status}, nil
 }
 
 // GetPayoutStatus queries the status of a RazorpayX payout
-func (a *Adapter) GetPayoutStatus(_ context.Context, gatewayPayoutID string) (*pg.PayoutStatusResponse, error) {
-	result, err := a.client.Request.Get(fmt.Sprintf("/v1/payouts/%s", gatewayPayoutID), nil, nil)
+func (a *Adapter) GetPayoutStatus(ctx context.Context, gatewayPayoutID string) (*pg.PayoutStatusResponse, error) {
+	client, err := a.api(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.Request.Get(fmt.Sprintf("/v1/payouts/%s", gatewayPayoutID), nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("razorpayx: get payout status failed: %s", describeError(err))
+		return nil, fmt.Errorf("razorpayx: get payout status failed: %w", classifyError(err))
 	}
 	status, _ := result["status"].(string)
 	failureReason, _ := result["failure_reason"].(string)
-	return &pg.PayoutStatusResponse{
+	details, _ := result["status_details"].(map[string]interface{})
+	reason, _ := details["reason"].(string)
+	description, _ := details["description"].(string)
+	if failureReason == "" {
+		failureReason = description
+	}
+	out := &pg.PayoutStatusResponse{
 		GatewayPayoutID: gatewayPayoutID,
+		State:           payoutState(status),
 		Status:          status,
 		FailureReason:   failureReason,
-	}, nil
+	}
+	switch out.State {
+	case pg.PayoutStateFailed, pg.PayoutStateReversed, pg.PayoutStateCancelled:
+		out.FailureCode = failureCode(status, reason, failureReason)
+	}
+	return out, nil
+}
+
+// HealthCheck lists a single contact to confirm the API keys are accepted
+func (a *Adapter) HealthCheck(ctx context.Context) pg.HealthCheckResult {
+	start := time.Now()
+	client, err := a.api(ctx)
+	if err != nil {
+		return pg.HealthCheckResult{Gateway: a.Name(), Err: err}
+	}
+	_, err = client.Request.Get("/v1/contacts", map[string]interface{}{"count": 1}, nil)
+	res := pg.HealthCheckResult{Gateway: a.Name(), Latency: time.Since(start)}
+	if err != nil {
+		res.Err = fmt.Errorf("razorpayx: health check failed: %w", classifyError(err))
+		return res
+	}
+	res.Healthy = true
+	return res
 }
 
 // VerifyWebhookSignature verifies the X-Razorpayx-Signature header
 func (a *Adapter) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, ok := a.VerifyWebhook(payload, headers)
+	return ok
+}
+
+// VerifyWebhook verifies the X-Razorpayx-Signature header against
+// WebhookSecret and every unexpired entry of WebhookSecrets, reporting which
+// one matched.
+func (a *Adapter) VerifyWebhook(payload []byte, headers map[string]string) (string, bool) {
 	sig := headers["x-razorpayx-signature"]
 	if sig == "" {
 		// Also try the payment webhook signature header (some setups share secrets)
 		sig = headers["x-razorpay-signature"]
 	}
-	if sig == "" || a.cfg.WebhookSecret == "" {
-		return false
+	if sig == "" {
+		return "", false
 	}
-	h := hmac.New(sha256.New, []byte(a.cfg.WebhookSecret))
-	h.Write(payload)
-	expected := hex.EncodeToString(h.Sum(nil))
-	return hmac.Equal([]byte(sig), []byte(expected))
+	primary := pg.LookupWebhookSecret(context.Background(), a.cfg.Secrets, pg.SecretRazorpayXWebhookSecret, a.cfg.WebhookSecret, a.cfg.OnSecretError)
+	for _, secret := range pg.ActiveWebhookSecrets(primary, a.cfg.WebhookSecrets, time.Now()) {
+		h := hmac.New(sha256.New, []byte(secret.Value))
+		h.Write(payload)
+		expected := hex.EncodeToString(h.Sum(nil))
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return secret.ID, true
+		}
+	}
+	return "", false
 }
 
 // ParseWebhookEvent parses a RazorpayX webhook payload
@@ -204,7 +311,12 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.PayoutWebhookEvent, err
 			Payout struct {
 				Entity struct {
 					ID            string `json:"id"`
+					Status        string `json:"status"`
 					FailureReason string `json:"failure_reason"`
+					StatusDetails struct {
+						Reason      string `json:"reason"`
+						Description string `json:"description"`
+					} `json:"status_details"`
 				} `json:"entity"`
 			} `json:"payout"`
 		} `json:"payload"`
@@ -213,9 +325,13 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.PayoutWebhookEvent, err
 		return nil, fmt.Errorf("razorpayx: failed to parse webhook: %w", err)
 	}
 
+	entity := envelope.Payload.Payout.Entity
 	evt := &pg.PayoutWebhookEvent{
-		GatewayPayoutID: envelope.Payload.Payout.Entity.ID,
-		FailureReason:   envelope.Payload.Payout.Entity.FailureReason,
+		GatewayPayoutID: entity.ID,
+		FailureReason:   entity.FailureReason,
+	}
+	if evt.FailureReason == "" {
+		evt.FailureReason = entity.StatusDetails.Description
 	}
 
 	switch {
@@ -228,10 +344,66 @@ func (a *Adapter) ParseWebhookEvent(payload []byte) (*pg.PayoutWebhookEvent, err
 	default:
 		evt.Type = pg.PayoutWebhookEventUnknown
 	}
+	if evt.Type == pg.PayoutWebhookEventFailed || evt.Type == pg.PayoutWebhookEventReversed {
+		status := entity.Status
+		if envelope.Event == "payout.rejected" {
+			status = "rejected"
+		}
+		evt.FailureCode = failureCode(status, entity.StatusDetails.Reason, evt.FailureReason)
+	}
 
 	return evt, nil
 }
 
+// payoutState maps a RazorpayX payout status onto a pg.PayoutState.
+func payoutState(status string) pg.PayoutState {
+	switch status {
+	case "queued", "pending", "scheduled":
+		return pg.PayoutStateQueued
+	case "processing":
+		return pg.PayoutStateProcessing
+	case "processed":
+		return pg.PayoutStateProcessed
+	case "failed":
+		return pg.PayoutStateFailed
+	case "reversed":
+		return pg.PayoutStateReversed
+	case "cancelled", "rejected":
+		return pg.PayoutStateCancelled
+	}
+	return pg.PayoutStateUnknown
+}
+
+// failureCode maps the status_details reason of a failed, reversed or
+// rejected payout (e.g. "beneficiary_bank_offline") onto a
+// pg.PayoutFailureCode. The free-text failure reason is matched as well,
+// since older payouts carry no reason code.
+func failureCode(status, reason, description string) pg.PayoutFailureCode {
+	if status == "rejected" {
+		return pg.PayoutFailureRejected
+	}
+	text := strings.ToLower(reason + " " + description)
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(text, w) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("balance"):
+		return pg.PayoutFailureInsufficientBalance
+	case has("limit"):
+		return pg.PayoutFailureLimitExceeded
+	case has("offline", "bank_down", "bank is down", "npci", "unavailable", "not available"):
+		return pg.PayoutFailureBeneficiaryBankDown
+	case has("invalid", "closed", "frozen", "dormant", "blocked", "does not exist"):
+		return pg.PayoutFailureInvalidBeneficiary
+	}
+	return pg.PayoutFailureOther
+}
+
 // describeError extracts a meaningful message from razorpay-go SDK errors
 func describeError(err error) string {
 	if err == nil {
@@ -252,3 +424,39 @@ func describeError(err error) string {
 		return "unknown error from RazorpayX (empty error message)"
 	}
 }
+
+// classifyError maps razorpay-go SDK errors onto a *pg.GatewayError. Server,
+// gateway and network errors are retriable. The SDK drops the HTTP status, so
+// bad requests are classified by their description.
+func classifyError(err error) error {
+	var netErr net.Error
+	switch e := err.(type) {
+	case *rzpErrors.ServerError:
+		return &pg.GatewayError{Gateway: "razorpayx", Kind: pg.ErrGatewayUnavailable, Code: "SERVER_ERROR", Message: describeError(err), Retriable: true, Err: err}
+	case *rzpErrors.GatewayError:
+		return &pg.GatewayError{Gateway: "razorpayx", Kind: pg.ErrGatewayUnavailable, Code: "GATEWAY_ERROR", Message: describeError(err), Retriable: true, Err: err}
+	case *rzpErrors.BadRequestError:
+		kind, retriable := badRequestKind(e.Message)
+		return &pg.GatewayError{Gateway: "razorpayx", Kind: kind, Code: "BAD_REQUEST_ERROR", Message: describeError(err), Retriable: retriable, Err: err}
+	}
+	if errors.As(err, &netErr) {
+		return &pg.GatewayError{Gateway: "razorpayx", Kind: pg.ErrGatewayUnavailable, Retriable: true, Err: err}
+	}
+	return errors.New(describeError(err))
+}
+
+// badRequestKind classifies a BAD_REQUEST_ERROR description.
+func badRequestKind(description string) (kind error, retriable bool) {
+	d := strings.ToLower(description)
+	switch {
+	case strings.Contains(d, "authentication failed"):
+		return pg.ErrUnauthenticated, false
+	case strings.Contains(d, "too many requests"), strings.Contains(d, "rate limit"):
+		return pg.ErrRateLimited, true
+	case strings.Contains(d, "does not exist"), strings.Contains(d, "not found"):
+		return pg.ErrNotFound, false
+	case strings.Contains(d, "not have enough balance"), strings.Contains(d, "insufficient balance"):
+		return pg.ErrInsufficientBalance, false
+	}
+	return pg.ErrInvalidRequest, false
+}
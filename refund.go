@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RefundState is the gateway-agnostic state of a refund
+type RefundState string
+
+const (
+	RefundStatePending   RefundState = "pending"   // accepted by the gateway, not yet sent to the customer's bank
+	RefundStateProcessed RefundState = "processed" // sent to the customer's bank
+	RefundStateFailed    RefundState = "failed"    // rejected or reversed; the amount stays with the merchant
+	RefundStateUnknown   RefundState = "unknown"   // gateway status not recognised
+)
+
+// RefundSpeed is how fast a refund reaches the customer
+type RefundSpeed string
+
+const (
+	RefundSpeedNormal  RefundSpeed = "normal"  // usual bank timelines, typically 5-7 working days
+	RefundSpeedInstant RefundSpeed = "instant" // credited within minutes, e.g. over IMPS or UPI
+)
+
+// RefundLookup identifies a refund for GetRefundStatus, or the payment whose
+// refunds ListRefunds returns. Which fields a gateway needs varies: Razorpay
+// looks refunds up by RefundID and lists them by GatewayPaymentID, while
+// Paytm looks them up by GatewayOrderID and RefundID and lists them by
+// GatewayOrderID.
+type RefundLookup struct {
+	GatewayOrderID   string
+	GatewayPaymentID string
+	RefundID         string // as in RefundResponse.RefundID; ignored by ListRefunds
+}
+
+// Refund is the current state of a refund as reported by the gateway
+type Refund struct {
+	RefundID         string // the gateway's ID, e.g. Razorpay's rfnd_ ID or Paytm's refundId
+	MerchantRefundID string // RefundRequest.RefundID, e.g. Razorpay's receipt or Paytm's refId
+	GatewayOrderID   string
+	GatewayPaymentID string
+	Amount           int64 // in the currency's minor unit
+	Currency         string
+	State            RefundState // normalised across gateways
+	Status           string      // gateway-specific status string, e.g. "processed" or "TXN_SUCCESS"
+	Speed            RefundSpeed // speed the refund was processed at; empty until known
+	ARN              string      // acquirer reference (ARN, RRN or UTR) the customer's bank can trace; empty until processed
+	CreatedAt        time.Time
+}
+
+// Money returns Amount and Currency as Money.
+func (r Refund) Money() Money { return Money{Amount: r.Amount, Currency: r.Currency} }
+
+// RefundQuerier is implemented by payment adapters that can look refunds up
+// after they were initiated, e.g. for a reconciliation job that cannot wait
+// for webhooks. The dynamic and tenant switchers implement it for every
+// registered gateway, failing with ErrNotImplemented when the resolved
+// adapter does not.
+type RefundQuerier interface {
+	// GetRefundStatus fetches a single refund. Unknown refunds fail with ErrNotFound.
+	GetRefundStatus(ctx context.Context, req RefundLookup) (*Refund, error)
+
+	// ListRefunds returns every refund of a payment, oldest first.
+	ListRefunds(ctx context.Context, req RefundLookup) ([]Refund, error)
+}
+
+// refundQuerier returns gw as a RefundQuerier, or an error wrapping
+// ErrNotImplemented naming the gateway.
+func refundQuerier(name string, gw interface{}) (RefundQuerier, error) {
+	q, ok := gw.(RefundQuerier)
+	if !ok {
+		return nil, fmt.Errorf("pg: %s: refund lookups: %w", name, ErrNotImplemented)
+	}
+	return q, nil
+}
+
+// getRefundStatusFunc and listRefundsFunc call gw's RefundQuerier methods,
+// failing with ErrNotImplemented when gw has none.
+func getRefundStatusFunc(gw interface{}, name string) func(context.Context, RefundLookup) (*Refund, error) {
+	return func(ctx context.Context, req RefundLookup) (*Refund, error) {
+		q, err := refundQuerier(name, gw)
+		if err != nil {
+			return nil, err
+		}
+		return q.GetRefundStatus(ctx, req)
+	}
+}
+
+func listRefundsFunc(gw interface{}, name string) func(context.Context, RefundLookup) ([]Refund, error) {
+	return func(ctx context.Context, req RefundLookup) ([]Refund, error) {
+		q, err := refundQuerier(name, gw)
+		if err != nil {
+			return nil, err
+		}
+		return q.ListRefunds(ctx, req)
+	}
+}
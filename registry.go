@@ -0,0 +1,101 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrGatewayRegistered is returned when registering a name that is taken
+	ErrGatewayRegistered = errors.New("pg-switcher: gateway already registered")
+
+	// ErrGatewayNotRegistered is returned when replacing or unregistering an unknown name
+	ErrGatewayNotRegistered = errors.New("pg-switcher: gateway not registered")
+)
+
+// registry is a concurrency-safe name -> adapter map. Writers publish a new
+// map instead of mutating the current one, so a snapshot taken by a reader
+// stays valid (and iteration over it safe) after later changes. Calls that
+// already hold an adapter keep using it until they return.
+type registry[T comparable] struct {
+	mu       sync.RWMutex
+	gateways map[string]T
+}
+
+func newRegistry[T comparable](gateways map[string]T) *registry[T] {
+	m := make(map[string]T, len(gateways))
+	for name, gw := range gateways {
+		m[name] = gw
+	}
+	return &registry[T]{gateways: m}
+}
+
+// snapshot returns the current map, which must not be modified.
+func (r *registry[T]) snapshot() map[string]T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.gateways
+}
+
+func (r *registry[T]) get(name string) (T, bool) {
+	gw, ok := r.snapshot()[name]
+	return gw, ok
+}
+
+func (r *registry[T]) names() []string {
+	return sortedKeys(r.snapshot())
+}
+
+// update applies fn to a copy of the map and publishes the copy.
+func (r *registry[T]) update(fn func(m map[string]T) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := make(map[string]T, len(r.gateways)+1)
+	for name, gw := range r.gateways {
+		m[name] = gw
+	}
+	if err := fn(m); err != nil {
+		return err
+	}
+	r.gateways = m
+	return nil
+}
+
+func (r *registry[T]) register(name string, gw T) error {
+	var zero T
+	if gw == zero {
+		return fmt.Errorf("pg-switcher: register %q: nil gateway", name)
+	}
+	return r.update(func(m map[string]T) error {
+		if _, ok := m[name]; ok {
+			return fmt.Errorf("%w: %q", ErrGatewayRegistered, name)
+		}
+		m[name] = gw
+		return nil
+	})
+}
+
+func (r *registry[T]) replace(name string, gw T) error {
+	var zero T
+	if gw == zero {
+		return fmt.Errorf("pg-switcher: replace %q: nil gateway", name)
+	}
+	return r.update(func(m map[string]T) error {
+		if _, ok := m[name]; !ok {
+			return fmt.Errorf("%w: %q", ErrGatewayNotRegistered, name)
+		}
+		m[name] = gw
+		return nil
+	})
+}
+
+func (r *registry[T]) unregister(name string) error {
+	return r.update(func(m map[string]T) error {
+		if _, ok := m[name]; !ok {
+			return fmt.Errorf("%w: %q", ErrGatewayNotRegistered, name)
+		}
+		delete(m, name)
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+package pg
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	a, b := &orderGateway{}, &orderGateway{}
+	tests := []struct {
+		name    string
+		op      func(r *registry[PaymentGateway]) error
+		wantErr error // nil means success
+		fail    bool  // an error is expected even when wantErr is nil
+		want    map[string]PaymentGateway
+	}{
+		{
+			name: "register",
+			op:   func(r *registry[PaymentGateway]) error { return r.register("b", b) },
+			want: map[string]PaymentGateway{"a": a, "b": b},
+		},
+		{
+			name:    "register taken name",
+			op:      func(r *registry[PaymentGateway]) error { return r.register("a", b) },
+			wantErr: ErrGatewayRegistered,
+			want:    map[string]PaymentGateway{"a": a},
+		},
+		{
+			name: "register nil",
+			op:   func(r *registry[PaymentGateway]) error { return r.register("b", nil) },
+			fail: true,
+			want: map[string]PaymentGateway{"a": a},
+		},
+		{
+			name: "replace",
+			op:   func(r *registry[PaymentGateway]) error { return r.replace("a", b) },
+			want: map[string]PaymentGateway{"a": b},
+		},
+		{
+			name:    "replace unknown name",
+			op:      func(r *registry[PaymentGateway]) error { return r.replace("b", b) },
+			wantErr: ErrGatewayNotRegistered,
+			want:    map[string]PaymentGateway{"a": a},
+		},
+		{
+			name: "replace with nil",
+			op:   func(r *registry[PaymentGateway]) error { return r.replace("a", nil) },
+			fail: true,
+			want: map[string]PaymentGateway{"a": a},
+		},
+		{
+			name: "unregister",
+			op:   func(r *registry[PaymentGateway]) error { return r.unregister("a") },
+			want: map[string]PaymentGateway{},
+		},
+		{
+			name:    "unregister unknown name",
+			op:      func(r *registry[PaymentGateway]) error { return r.unregister("b") },
+			wantErr: ErrGatewayNotRegistered,
+			want:    map[string]PaymentGateway{"a": a},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRegistry(map[string]PaymentGateway{"a": a})
+			before := r.snapshot()
+
+			err := tt.op(r)
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			case tt.wantErr == nil && tt.fail && err == nil:
+				t.Fatal("error = nil, want an error")
+			case tt.wantErr == nil && !tt.fail && err != nil:
+				t.Fatalf("error = %v, want nil", err)
+			}
+			if got := r.snapshot(); !sameGateways(got, tt.want) {
+				t.Fatalf("gateways = %v, want %v", got, tt.want)
+			}
+			if !sameGateways(before, map[string]PaymentGateway{"a": a}) {
+				t.Fatalf("earlier snapshot changed to %v", before)
+			}
+		})
+	}
+}
+
+func TestRegistryCopiesInitialMap(t *testing.T) {
+	initial := map[string]PaymentGateway{"a": &orderGateway{}}
+	r := newRegistry(initial)
+	initial["b"] = &orderGateway{}
+	if got := r.names(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("names() = %v, want [a]", got)
+	}
+}
+
+// sameGateways reports whether a and b hold the same adapters by identity.
+func sameGateways(a, b map[string]PaymentGateway) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, gw := range a {
+		if b[name] != gw {
+			return false
+		}
+	}
+	return true
+}
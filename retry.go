@@ -0,0 +1,142 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+type noRetryCtxKey struct{}
+
+// WithoutRetry returns a context that disables retries for calls made with it,
+// e.g. when the caller runs its own retry loop or is close to a deadline.
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryCtxKey{}, true)
+}
+
+func retryDisabled(ctx context.Context) bool {
+	off, _ := ctx.Value(noRetryCtxKey{}).(bool)
+	return off
+}
+
+// RetryPolicy configures retries of transient gateway failures. Zero fields
+// take the defaults noted below.
+//
+// Only calls that are safe to repeat are retried: VerifyPayment,
+// GetPaymentStatus, GetRefundStatus, ListRefunds and GetPayoutStatus always, and InitiatePayout when the
+// request has a ReferenceID, which the payout gateways use as an idempotency
+// key. Other operations are never retried, since repeating them could create
+// a second order or refund.
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts including the first (default 3)
+	InitialBackoff time.Duration // wait before the first retry (default 200ms)
+	MaxBackoff     time.Duration // cap on a single wait (default 2s)
+	Multiplier     float64       // backoff growth per attempt (default 2)
+	Jitter         float64       // random +/- fraction applied to each wait, 0..1 (default 0.2)
+	MaxElapsed     time.Duration // give up once another wait would exceed this (default 10s)
+
+	// Retriable decides whether an error is worth retrying (default IsRetriable).
+	// Errors from an open circuit are never retried.
+	Retriable func(error) bool
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 200 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 2 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter <= 0 {
+		p.Jitter = 0.2
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.MaxElapsed <= 0 {
+		p.MaxElapsed = 10 * time.Second
+	}
+	if p.Retriable == nil {
+		p.Retriable = IsRetriable
+	}
+	return p
+}
+
+// WithRetryPolicy makes the dynamic switchers retry transient failures of
+// safe operations (see RetryPolicy). Each attempt goes through the
+// interceptors and the circuit breaker separately.
+func WithRetryPolicy(p RetryPolicy) SwitcherOption {
+	return func(o *switcherOptions) {
+		p = p.withDefaults()
+		o.retry = &p
+	}
+}
+
+// RetryInterceptor returns an Interceptor applying p, for use with
+// WrapPaymentGateway and WrapPayoutGateway. Place it before interceptors that
+// should see every attempt, such as tracing or metrics.
+func RetryInterceptor(p RetryPolicy) Interceptor {
+	p = p.withDefaults()
+	return func(ctx context.Context, inv *Invocation, next Handler) (interface{}, error) {
+		var resp interface{}
+		err := p.do(ctx, inv.Operation, inv.Request, func(ctx context.Context) error {
+			var err error
+			resp, err = next(ctx, inv.Request)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// retryable reports whether op may be repeated with the given request.
+func retryable(op string, req interface{}) bool {
+	switch op {
+	case OpVerifyPayment, OpGetPaymentStatus, OpGetRefundStatus, OpListRefunds, OpGetPayoutStatus:
+		return true
+	case OpInitiatePayout:
+		r, ok := req.(InitiatePayoutRequest)
+		return ok && r.ReferenceID != ""
+	}
+	return false
+}
+
+// do runs attempt, retrying per the policy. p must have defaults applied; a
+// nil p runs attempt once.
+func (p *RetryPolicy) do(ctx context.Context, op string, req interface{}, attempt func(context.Context) error) error {
+	if p == nil || retryDisabled(ctx) || !retryable(op, req) {
+		return attempt(ctx)
+	}
+	start := time.Now()
+	backoff := p.InitialBackoff
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil || n >= p.MaxAttempts || errors.Is(err, ErrCircuitOpen) || !p.Retriable(err) {
+			return err
+		}
+		wait := time.Duration(float64(backoff) * (1 + p.Jitter*(2*rand.Float64()-1)))
+		if time.Since(start)+wait > p.MaxElapsed {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		backoff = time.Duration(float64(backoff) * p.Multiplier)
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	p := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}.withDefaults()
+	return &p
+}
+
+func TestRetryPolicy(t *testing.T) {
+	errPlain := errors.New("boom")
+	tests := []struct {
+		name      string
+		op        string
+		req       interface{}
+		errs      []error // returned by successive attempts; nil after the last
+		noRetry   bool
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", OpGetPaymentStatus, nil, nil, false, 1, nil},
+		{"retries until success", OpGetPaymentStatus, nil, []error{errUnavailable, errUnavailable}, false, 3, nil},
+		{"gives up after MaxAttempts", OpGetRefundStatus, nil, []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}, false, 3, ErrGatewayUnavailable},
+		{"stops on a non-retriable error", OpVerifyPayment, nil, []error{errUnavailable, ErrInvalidRequest}, false, 2, ErrInvalidRequest},
+		{"stops on an unclassified error", OpListRefunds, nil, []error{errPlain}, false, 1, errPlain},
+		{"honours MarkRetriable", OpGetPayoutStatus, nil, []error{MarkRetriable(errPlain)}, false, 2, nil},
+		{"never retries an open circuit", OpGetPaymentStatus, nil, []error{&CircuitOpenError{Gateway: "test"}}, false, 1, ErrCircuitOpen},
+		{"never retries CreateOrder", OpCreateOrder, CreateOrderRequest{}, []error{errUnavailable}, false, 1, ErrGatewayUnavailable},
+		{"never retries InitiateRefund", OpInitiateRefund, RefundRequest{}, []error{errUnavailable}, false, 1, ErrGatewayUnavailable},
+		{"retries a payout with a ReferenceID", OpInitiatePayout, InitiatePayoutRequest{ReferenceID: "ref_1"}, []error{errUnavailable}, false, 2, nil},
+		{"never retries a payout without a ReferenceID", OpInitiatePayout, InitiatePayoutRequest{}, []error{errUnavailable}, false, 1, ErrGatewayUnavailable},
+		{"WithoutRetry", OpGetPaymentStatus, nil, []error{errUnavailable}, true, 1, ErrGatewayUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.noRetry {
+				ctx = WithoutRetry(ctx)
+			}
+			calls := 0
+			err := testRetryPolicy().do(ctx, tt.op, tt.req, func(context.Context) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("do() = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("%d attempts, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyNil(t *testing.T) {
+	var p *RetryPolicy
+	calls := 0
+	p.do(context.Background(), OpGetPaymentStatus, nil, func(context.Context) error {
+		calls++
+		return errUnavailable
+	})
+	if calls != 1 {
+		t.Fatalf("%d attempts without a policy, want 1", calls)
+	}
+}
+
+func TestRetryStopsOnContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+	}{
+		{"cancelled during backoff", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return ctx, cancel
+		}},
+		{"deadline before the next attempt", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			p := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxElapsed: time.Minute}.withDefaults()
+			calls := 0
+			start := time.Now()
+			err := p.do(ctx, OpGetPaymentStatus, nil, func(context.Context) error {
+				calls++
+				return errUnavailable
+			})
+			if !errors.Is(err, ErrGatewayUnavailable) || calls != 1 {
+				t.Fatalf("do() = %v after %d attempts, want the first attempt's error", err, calls)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Fatalf("do() returned after %v, want it to stop with the context", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, InitialBackoff: 20 * time.Millisecond, MaxElapsed: 30 * time.Millisecond}.withDefaults()
+	calls := 0
+	p.do(context.Background(), OpGetPaymentStatus, nil, func(context.Context) error {
+		calls++
+		return errUnavailable
+	})
+	if calls < 2 || calls > 3 {
+		t.Fatalf("%d attempts within MaxElapsed, want 2 or 3", calls)
+	}
+}
+
+func TestSwitcherRetry(t *testing.T) {
+	gateways := newStubs("a")
+	gw := gateways["a"].(*stubGateway)
+	gw.statusErrs = []error{errUnavailable, errUnavailable}
+	var attempts int
+	s := NewDynamicPaymentSwitcher(gateways, StaticResolver("a"),
+		WithRetryPolicy(RetryPolicy{InitialBackoff: time.Millisecond}),
+		WithInterceptors(func(ctx context.Context, inv *Invocation, next Handler) (interface{}, error) {
+			attempts++
+			return next(ctx, inv.Request)
+		}))
+
+	status, err := s.GetPaymentStatus(context.Background(), "order_a")
+	if err != nil || status.State != PaymentStateCaptured {
+		t.Fatalf("GetPaymentStatus = %+v, %v; want a captured payment", status, err)
+	}
+	if attempts != 3 || gw.statusCalls.Load() != 3 {
+		t.Fatalf("interceptor saw %d attempts, gateway %d; want 3 each", attempts, gw.statusCalls.Load())
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	gw := &stubGateway{name: "a", statusErrs: []error{errUnavailable}}
+	wrapped := WrapPaymentGateway(gw, RetryInterceptor(RetryPolicy{InitialBackoff: time.Millisecond}))
+	if _, err := wrapped.GetPaymentStatus(context.Background(), "order_a"); err != nil {
+		t.Fatalf("GetPaymentStatus = %v, want success on the second attempt", err)
+	}
+	if got := gw.statusCalls.Load(); got != 2 {
+		t.Fatalf("%d status calls, want 2", got)
+	}
+}
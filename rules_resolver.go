@@ -0,0 +1,295 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+type orderRequestCtxKey struct{}
+type payoutRequestCtxKey struct{}
+
+// withOrderRequest attaches the order being created so resolvers can route on it.
+func withOrderRequest(ctx context.Context, req CreateOrderRequest) context.Context {
+	return context.WithValue(ctx, orderRequestCtxKey{}, req)
+}
+
+// withPayoutRequest attaches the payout being initiated so resolvers can route on it.
+func withPayoutRequest(ctx context.Context, req InitiatePayoutRequest) context.Context {
+	return context.WithValue(ctx, payoutRequestCtxKey{}, req)
+}
+
+// OrderRequestFromContext returns the CreateOrderRequest that the
+// DynamicPaymentSwitcher is resolving a gateway for, if any.
+func OrderRequestFromContext(ctx context.Context) (CreateOrderRequest, bool) {
+	req, ok := ctx.Value(orderRequestCtxKey{}).(CreateOrderRequest)
+	return req, ok
+}
+
+// PayoutRequestFromContext returns the InitiatePayoutRequest that the
+// DynamicPayoutSwitcher is resolving a gateway for, if any.
+func PayoutRequestFromContext(ctx context.Context) (InitiatePayoutRequest, bool) {
+	req, ok := ctx.Value(payoutRequestCtxKey{}).(InitiatePayoutRequest)
+	return req, ok
+}
+
+// Rule operators
+const (
+	RuleOpEq     = "eq" // case-insensitive string equality
+	RuleOpNe     = "ne" // case-insensitive string inequality
+	RuleOpGt     = "gt" // integer comparisons, e.g. on amount
+	RuleOpGte    = "gte"
+	RuleOpLt     = "lt"
+	RuleOpLte    = "lte"
+	RuleOpIn     = "in"     // case-insensitive membership in Values
+	RuleOpPrefix = "prefix" // string prefix
+)
+
+// RuleCondition compares one request field against a value.
+//
+// Order fields: "amount", "currency", "receipt", "authorize_only" ("true" or
+// "false") and "notes.<key>".
+// Payout fields: "amount", "currency", "mode", "fund_account_id",
+// "reference_id" and "narration". Amounts are in the smallest currency unit.
+// A condition on a field the request does not have never matches.
+type RuleCondition struct {
+	Field  string   `json:"field" yaml:"field"`
+	Op     string   `json:"op" yaml:"op"`
+	Value  string   `json:"value,omitempty" yaml:"value,omitempty"`
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"` // for "in"
+}
+
+// Rule routes to Gateway when all of its conditions match.
+type Rule struct {
+	Name    string          `json:"name,omitempty" yaml:"name,omitempty"`
+	Gateway string          `json:"gateway" yaml:"gateway"`
+	When    []RuleCondition `json:"when" yaml:"when"`
+}
+
+// RuleSet is an ordered list of rules; the first matching rule wins and
+// Default is used when none match.
+type RuleSet struct {
+	Rules   []Rule `json:"rules" yaml:"rules"`
+	Default string `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// ParseRuleSetJSON decodes and validates a JSON rule set.
+func ParseRuleSetJSON(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("pg-switcher: parse rules: %w", err)
+	}
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// ParseRuleSetYAML decodes and validates a YAML rule set.
+func ParseRuleSetYAML(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	if err := yaml.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("pg-switcher: parse rules: %w", err)
+	}
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// Validate checks every rule and reports all problems at once.
+func (rs *RuleSet) Validate() error {
+	if rs == nil {
+		return fmt.Errorf("pg-switcher: invalid rules: nil rule set")
+	}
+	var errs []error
+	for i, r := range rs.Rules {
+		label := fmt.Sprintf("rule %d", i)
+		if r.Name != "" {
+			label = fmt.Sprintf("rule %d (%s)", i, r.Name)
+		}
+		if r.Gateway == "" {
+			errs = append(errs, fmt.Errorf("%s: missing gateway", label))
+		}
+		for j, c := range r.When {
+			if err := c.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s condition %d: %w", label, j, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("pg-switcher: invalid rules: %w", err)
+	}
+	return nil
+}
+
+func (c RuleCondition) validate() error {
+	if c.Field == "" {
+		return fmt.Errorf("missing field")
+	}
+	switch c.Op {
+	case RuleOpEq, RuleOpNe, RuleOpPrefix:
+	case RuleOpGt, RuleOpGte, RuleOpLt, RuleOpLte:
+		if _, err := strconv.ParseInt(c.Value, 10, 64); err != nil {
+			return fmt.Errorf("op %q needs an integer value, got %q", c.Op, c.Value)
+		}
+	case RuleOpIn:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("op %q needs values", c.Op)
+		}
+	default:
+		return fmt.Errorf("unknown op %q", c.Op)
+	}
+	return nil
+}
+
+// match evaluates the condition against the request field lookup.
+func (c RuleCondition) match(field func(string) (string, bool)) bool {
+	v, ok := field(c.Field)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case RuleOpEq:
+		return strings.EqualFold(v, c.Value)
+	case RuleOpNe:
+		return !strings.EqualFold(v, c.Value)
+	case RuleOpPrefix:
+		return strings.HasPrefix(v, c.Value)
+	case RuleOpIn:
+		for _, want := range c.Values {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+		return false
+	}
+	got, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return false
+	}
+	want, _ := strconv.ParseInt(c.Value, 10, 64)
+	switch c.Op {
+	case RuleOpGt:
+		return got > want
+	case RuleOpGte:
+		return got >= want
+	case RuleOpLt:
+		return got < want
+	case RuleOpLte:
+		return got <= want
+	}
+	return false
+}
+
+// RulesResolver routes on attributes of the order or payout being created.
+// Calls that carry no request (e.g. GetPaymentStatus) match no field
+// conditions and fall through to the default.
+type RulesResolver struct {
+	fallback GatewayResolver
+
+	mu    sync.RWMutex
+	rules *RuleSet
+}
+
+// NewRulesResolver creates a RulesResolver. When no rule matches, the rule
+// set's Default is used; if that is empty, fallback is called instead.
+func NewRulesResolver(rules *RuleSet, fallback GatewayResolver) (*RulesResolver, error) {
+	r := &RulesResolver{fallback: fallback}
+	if err := r.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetRules validates and atomically replaces the rule set. A nil rule set is
+// rejected.
+func (r *RulesResolver) SetRules(rules *RuleSet) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	if rules.Default == "" && r.fallback == nil {
+		return fmt.Errorf("pg-switcher: rules need a default gateway or a fallback resolver")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = rules
+	return nil
+}
+
+// Resolve picks a gateway. Its signature matches GatewayResolver, so it can be
+// passed directly as r.Resolve.
+func (r *RulesResolver) Resolve(ctx context.Context) (string, error) {
+	r.mu.RLock()
+	rules := r.rules
+	r.mu.RUnlock()
+
+	field := requestFields(ctx)
+	for _, rule := range rules.Rules {
+		if rule.matches(field) {
+			return rule.Gateway, nil
+		}
+	}
+	if rules.Default != "" {
+		return rules.Default, nil
+	}
+	return r.fallback(ctx)
+}
+
+func (r Rule) matches(field func(string) (string, bool)) bool {
+	for _, c := range r.When {
+		if !c.match(field) {
+			return false
+		}
+	}
+	return true
+}
+
+// requestFields returns a field lookup over the request attached to ctx.
+func requestFields(ctx context.Context) func(string) (string, bool) {
+	if req, ok := OrderRequestFromContext(ctx); ok {
+		return func(name string) (string, bool) {
+			switch name {
+			case "amount":
+				return strconv.FormatInt(req.Amount, 10), true
+			case "currency":
+				return req.Currency, true
+			case "receipt":
+				return req.Receipt, true
+			case "authorize_only":
+				return strconv.FormatBool(req.AuthorizeOnly), true
+			}
+			if key, ok := strings.CutPrefix(name, "notes."); ok {
+				v, ok := req.Notes[key]
+				return v, ok
+			}
+			return "", false
+		}
+	}
+	if req, ok := PayoutRequestFromContext(ctx); ok {
+		return func(name string) (string, bool) {
+			switch name {
+			case "amount":
+				return strconv.FormatInt(req.Amount, 10), true
+			case "currency":
+				return req.Currency, true
+			case "mode":
+				return req.Mode, true
+			case "fund_account_id":
+				return req.FundAccountID, true
+			case "reference_id":
+				return req.ReferenceID, true
+			case "narration":
+				return req.Narration, true
+			}
+			return "", false
+		}
+	}
+	return func(string) (string, bool) { return "", false }
+}
@@ -0,0 +1,167 @@
+package pg
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRuleConditionMatch(t *testing.T) {
+	order := CreateOrderRequest{Amount: 150000, Currency: "INR", Receipt: "corp_42", Notes: map[string]string{"tier": "gold"}}
+	tests := []struct {
+		name string
+		cond RuleCondition
+		want bool
+	}{
+		{"eq", RuleCondition{Field: "currency", Op: RuleOpEq, Value: "inr"}, true},
+		{"eq mismatch", RuleCondition{Field: "currency", Op: RuleOpEq, Value: "USD"}, false},
+		{"ne", RuleCondition{Field: "currency", Op: RuleOpNe, Value: "USD"}, true},
+		{"ne ignores case", RuleCondition{Field: "currency", Op: RuleOpNe, Value: "inr"}, false},
+		{"gt", RuleCondition{Field: "amount", Op: RuleOpGt, Value: "149999"}, true},
+		{"gt equal", RuleCondition{Field: "amount", Op: RuleOpGt, Value: "150000"}, false},
+		{"gte equal", RuleCondition{Field: "amount", Op: RuleOpGte, Value: "150000"}, true},
+		{"lt", RuleCondition{Field: "amount", Op: RuleOpLt, Value: "150000"}, false},
+		{"lte equal", RuleCondition{Field: "amount", Op: RuleOpLte, Value: "150000"}, true},
+		{"gt on a non-numeric field", RuleCondition{Field: "currency", Op: RuleOpGt, Value: "1"}, false},
+		{"in", RuleCondition{Field: "currency", Op: RuleOpIn, Values: []string{"usd", "inr"}}, true},
+		{"in mismatch", RuleCondition{Field: "currency", Op: RuleOpIn, Values: []string{"usd", "eur"}}, false},
+		{"prefix", RuleCondition{Field: "receipt", Op: RuleOpPrefix, Value: "corp_"}, true},
+		{"prefix is case-sensitive", RuleCondition{Field: "receipt", Op: RuleOpPrefix, Value: "CORP_"}, false},
+		{"note", RuleCondition{Field: "notes.tier", Op: RuleOpEq, Value: "gold"}, true},
+		{"missing note", RuleCondition{Field: "notes.region", Op: RuleOpNe, Value: "eu"}, false},
+		{"authorize_only", RuleCondition{Field: "authorize_only", Op: RuleOpEq, Value: "false"}, true},
+		{"unknown field", RuleCondition{Field: "mode", Op: RuleOpNe, Value: "IMPS"}, false},
+	}
+	field := requestFields(withOrderRequest(context.Background(), order))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.match(field); got != tt.want {
+				t.Fatalf("match(%+v) = %v, want %v", tt.cond, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSetValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules *RuleSet
+		want  []string // substrings of the error; none means valid
+	}{
+		{"valid", &RuleSet{Rules: []Rule{{Gateway: "paytm", When: []RuleCondition{{Field: "amount", Op: RuleOpGt, Value: "100"}}}}}, nil},
+		{"empty", &RuleSet{}, nil},
+		{"nil", nil, []string{"nil rule set"}},
+		{
+			name: "every problem reported",
+			rules: &RuleSet{Rules: []Rule{
+				{Name: "big", When: []RuleCondition{{Field: "amount", Op: RuleOpGt, Value: "lots"}}},
+				{Gateway: "paytm", When: []RuleCondition{{Op: RuleOpEq}, {Field: "currency", Op: "like"}, {Field: "currency", Op: RuleOpIn}}},
+			}},
+			want: []string{
+				"rule 0 (big): missing gateway",
+				`rule 0 (big) condition 0: op "gt" needs an integer value`,
+				"rule 1 condition 0: missing field",
+				`rule 1 condition 1: unknown op "like"`,
+				`rule 1 condition 2: op "in" needs values`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() = nil, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %q, missing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRuleSet(t *testing.T) {
+	const js = `{"rules":[{"gateway":"paytm","when":[{"field":"currency","op":"eq","value":"INR"}]}],"default":"razorpay"}`
+	const ym = "rules:\n  - gateway: paytm\n    when:\n      - field: currency\n        op: eq\n        value: INR\ndefault: razorpay\n"
+	for name, parse := range map[string]func() (*RuleSet, error){
+		"json": func() (*RuleSet, error) { return ParseRuleSetJSON([]byte(js)) },
+		"yaml": func() (*RuleSet, error) { return ParseRuleSetYAML([]byte(ym)) },
+	} {
+		rs, err := parse()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rs.Default != "razorpay" || len(rs.Rules) != 1 || rs.Rules[0].When[0].Value != "INR" {
+			t.Fatalf("%s: parsed %+v", name, rs)
+		}
+	}
+	if _, err := ParseRuleSetJSON([]byte(`{"rules":[{"when":[]}]}`)); err == nil {
+		t.Fatal("ParseRuleSetJSON accepted a rule without a gateway")
+	}
+}
+
+func TestRulesResolver(t *testing.T) {
+	rules := &RuleSet{Rules: []Rule{
+		{Gateway: "razorpay", When: []RuleCondition{{Field: "authorize_only", Op: RuleOpEq, Value: "true"}}},
+		{Gateway: "paytm", When: []RuleCondition{{Field: "amount", Op: RuleOpGte, Value: "100000"}, {Field: "currency", Op: RuleOpEq, Value: "INR"}}},
+	}}
+	fallback := func(context.Context) (string, error) { return "fallback", nil }
+	r, err := NewRulesResolver(rules, fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"first matching rule wins", withOrderRequest(context.Background(), CreateOrderRequest{Amount: 200000, Currency: "INR", AuthorizeOnly: true}), "razorpay"},
+		{"all conditions must match", withOrderRequest(context.Background(), CreateOrderRequest{Amount: 200000, Currency: "USD"}), "fallback"},
+		{"rule matches", withOrderRequest(context.Background(), CreateOrderRequest{Amount: 100000, Currency: "INR"}), "paytm"},
+		{"no request", context.Background(), "fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := r.Resolve(tt.ctx); err != nil || got != tt.want {
+				t.Fatalf("Resolve() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if err := r.SetRules(&RuleSet{Default: "paytm"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Resolve(context.Background()); got != "paytm" {
+		t.Fatalf("after SetRules: Resolve() = %q, want the new default", got)
+	}
+}
+
+func TestRulesResolverRejects(t *testing.T) {
+	if _, err := NewRulesResolver(nil, nil); err == nil {
+		t.Fatal("NewRulesResolver(nil) = nil error")
+	}
+	if _, err := NewRulesResolver(&RuleSet{}, nil); err == nil {
+		t.Fatal("NewRulesResolver without a default or fallback = nil error")
+	}
+	r, err := NewRulesResolver(&RuleSet{Default: "paytm"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetRules(nil); err == nil {
+		t.Fatal("SetRules(nil) = nil error")
+	}
+	bad := &RuleSet{Rules: []Rule{{When: []RuleCondition{{Field: "amount", Op: "between"}}}}}
+	if err := r.SetRules(bad); err == nil {
+		t.Fatal("SetRules accepted invalid rules")
+	}
+	if got, _ := r.Resolve(context.Background()); got != "paytm" {
+		t.Fatalf("Resolve() after rejected SetRules = %q, want the old rules kept", got)
+	}
+}
@@ -0,0 +1,165 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secret names looked up by the adapters. They match the environment variable
+// names read by LoadConfigFromEnv, without the prefix.
+const (
+	SecretRazorpayKeyID          = "RAZORPAY_KEY_ID"
+	SecretRazorpayKeySecret      = "RAZORPAY_KEY_SECRET"
+	SecretRazorpayWebhookSecret  = "RAZORPAY_WEBHOOK_SECRET"
+	SecretRazorpayXKeyID         = "RAZORPAYX_KEY_ID"
+	SecretRazorpayXKeySecret     = "RAZORPAYX_KEY_SECRET"
+	SecretRazorpayXWebhookSecret = "RAZORPAYX_WEBHOOK_SECRET"
+	SecretPaytmMerchantKey       = "PAYTM_MERCHANT_KEY"
+	SecretPaytmWebhookSecret     = "PAYTM_WEBHOOK_SECRET"
+)
+
+// ErrSecretNotFound is returned by a SecretProvider that has no value for a
+// name. Adapters then fall back to the value in their Config.
+var ErrSecretNotFound = errors.New("pg: secret not found")
+
+// SecretProvider supplies credentials at call time, so that rotated secrets
+// take effect without rebuilding adapters or switchers. Implementations must
+// be safe for concurrent use; wrap slow sources with NewCachingSecretProvider.
+type SecretProvider interface {
+	// Secret returns the current value of the named secret (one of the
+	// Secret* constants), or an error wrapping ErrSecretNotFound.
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretProviderFunc adapts a callback, e.g. a Vault or AWS Secrets Manager
+// lookup, to SecretProvider.
+type SecretProviderFunc func(ctx context.Context, name string) (string, error)
+
+// Secret implements SecretProvider.
+func (f SecretProviderFunc) Secret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// EnvSecretProvider reads secrets from environment variables named Prefix
+// followed by the secret name, e.g. PG_RAZORPAY_KEY_SECRET.
+type EnvSecretProvider struct {
+	Prefix string
+}
+
+// Secret implements SecretProvider. Empty variables count as not found.
+func (p EnvSecretProvider) Secret(_ context.Context, name string) (string, error) {
+	if v := os.Getenv(p.Prefix + name); v != "" {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s%s", ErrSecretNotFound, p.Prefix, name)
+}
+
+// FileSecretProvider reads each secret from a file named after it in Dir, e.g.
+// a mounted Kubernetes secret. Surrounding whitespace is trimmed.
+type FileSecretProvider struct {
+	Dir string
+}
+
+// Secret implements SecretProvider. Missing and empty files count as not found.
+func (p FileSecretProvider) Secret(_ context.Context, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("pg: read secret %s: %w", name, err)
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "" {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return v, nil
+}
+
+// LookupWebhookSecret is LookupSecret for secrets that verify webhooks. These
+// must keep working through a secret store outage, so a provider error other
+// than ErrSecretNotFound is passed to onError, which may be nil, and fallback
+// is returned.
+func LookupWebhookSecret(ctx context.Context, p SecretProvider, name, fallback string, onError func(name string, err error)) string {
+	v, err := LookupSecret(ctx, p, name, fallback)
+	if err != nil {
+		if onError != nil {
+			onError(name, err)
+		}
+		return fallback
+	}
+	return v
+}
+
+type cachedSecret struct {
+	value   string
+	err     error
+	fetched time.Time
+}
+
+// CachingSecretProvider caches another provider's answers for a refresh
+// interval. When a refresh fails, the last good value keeps being served.
+type CachingSecretProvider struct {
+	provider SecretProvider
+	refresh  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedSecret
+}
+
+// NewCachingSecretProvider wraps p so that each secret is fetched at most once
+// per refresh interval.
+func NewCachingSecretProvider(p SecretProvider, refresh time.Duration) *CachingSecretProvider {
+	return &CachingSecretProvider{provider: p, refresh: refresh, entries: make(map[string]cachedSecret)}
+}
+
+// Secret implements SecretProvider.
+func (c *CachingSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && time.Since(e.fetched) < c.refresh {
+		return e.value, e.err
+	}
+
+	v, err := c.provider.Secret(ctx, name)
+	if err != nil && !errors.Is(err, ErrSecretNotFound) {
+		if ok && e.err == nil {
+			return e.value, nil
+		}
+		return "", err
+	}
+	c.mu.Lock()
+	c.entries[name] = cachedSecret{value: v, err: err, fetched: time.Now()}
+	c.mu.Unlock()
+	return v, err
+}
+
+// Invalidate drops all cached values, e.g. after an out-of-band rotation.
+func (c *CachingSecretProvider) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedSecret)
+}
+
+// LookupSecret returns the named secret from p, or fallback when p is nil or
+// has no value for it. Adapters call it on every use of a credential.
+func LookupSecret(ctx context.Context, p SecretProvider, name, fallback string) (string, error) {
+	if p == nil {
+		return fallback, nil
+	}
+	v, err := p.Secret(ctx, name)
+	if errors.Is(err, ErrSecretNotFound) {
+		return fallback, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("pg: secret %s: %w", name, err)
+	}
+	return v, nil
+}
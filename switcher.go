@@ -2,214 +2,643 @@ package pg
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 )
 
+// ErrWebhookSignatureMismatch is returned by VerifyAndParseWebhook when no
+// registered gateway accepts the webhook signature.
+var ErrWebhookSignatureMismatch = errors.New("pg-switcher: webhook signature did not match any registered gateway")
+
 // GatewayResolver is called on every operation to determine which gateway to use.
 // This allows admin config changes to take effect immediately without restart.
 type GatewayResolver func(ctx context.Context) (string, error)
 
+// SwitcherOption configures optional behaviour of the dynamic switchers.
+type SwitcherOption func(*switcherOptions)
+
+type switcherOptions struct {
+	bindings    BindingStore
+	failover    GatewayListResolver
+	breakers    *breakerSet
+	interceptor Interceptor
+	retry       *RetryPolicy
+	onResolve   func(ctx context.Context, gateway string)
+}
+
+func newSwitcherOptions(opts []SwitcherOption) switcherOptions {
+	var o switcherOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// intercept runs fn against the named gateway through the interceptor chain.
+func intercept[Req, Resp any](ctx context.Context, o *switcherOptions, name, op string, req Req, fn func(context.Context, Req) (Resp, error)) (Resp, error) {
+	return invoke(ctx, o.interceptor, Invocation{Gateway: name, Operation: op, Request: req, Dynamic: true}, fn)
+}
+
+// verifyWebhook runs a gateway's signature check through the interceptor
+// chain, returning the ID of the matching secret when the adapter reports one.
+func verifyWebhook(ctx context.Context, o *switcherOptions, name string, gw webhookVerifier, payload []byte, headers map[string]string) (string, bool) {
+	var secretID string
+	ok, _ := intercept(ctx, o, name, OpVerifyWebhookSignature, WebhookPayload{payload, headers}, verifyWebhookFunc(gw, &secretID))
+	return secretID, ok
+}
+
+// call runs fn against the named gateway through the interceptor chain and
+// then the gateway's circuit breaker, when those are configured. Safe
+// operations are retried as a whole under the retry policy.
+func call[Req, Resp any](ctx context.Context, o *switcherOptions, name, op string, req Req, fn func(context.Context, Req) (Resp, error)) (Resp, error) {
+	var resp Resp
+	err := o.retry.do(ctx, op, req, func(ctx context.Context) error {
+		var err error
+		resp, err = intercept(ctx, o, name, op, req, func(ctx context.Context, req Req) (Resp, error) {
+			if o.breakers == nil {
+				return fn(ctx, req)
+			}
+			b := o.breakers.get(name)
+			gen, err := b.allow()
+			if err != nil {
+				var zero Resp
+				return zero, err
+			}
+			resp, err := fn(ctx, req)
+			if err != nil && ctx.Err() != nil {
+				b.release(gen) // the caller gave up; the gateway's health is unknown
+			} else {
+				b.record(gen, err)
+			}
+			return resp, err
+		})
+		return err
+	})
+	return resp, err
+}
+
+// health returns a breaker snapshot for each name, or nil if breakers are disabled.
+func (o *switcherOptions) health(names []string) []GatewayHealth {
+	if o.breakers == nil {
+		return nil
+	}
+	out := make([]GatewayHealth, 0, len(names))
+	for _, name := range names {
+		out = append(out, o.breakers.get(name).snapshot())
+	}
+	return out
+}
+
+// resetBreaker forgets the breaker state of name, if breakers are enabled.
+func (o *switcherOptions) resetBreaker(name string) {
+	if o.breakers != nil {
+		o.breakers.reset(name)
+	}
+}
+
+// WithResolveObserver registers fn to be called with the gateway name every
+// time the resolver picks a registered gateway, e.g. to export the active
+// gateway as a metric. With failover, it is called with the gateway that
+// served the order. Calls routed through a binding do not trigger it.
+func WithResolveObserver(fn func(ctx context.Context, gateway string)) SwitcherOption {
+	return func(o *switcherOptions) { o.onResolve = fn }
+}
+
+// resolved reports a resolver decision to the observer, if any.
+func (o *switcherOptions) resolved(ctx context.Context, name string) {
+	if o.onResolve != nil {
+		o.onResolve(ctx, name)
+	}
+}
+
+// WithBindingStore makes the switcher remember which gateway created each
+// order and payment. Follow-up calls (VerifyPayment, GetPaymentStatus,
+// InitiateRefund) are then routed to that gateway regardless of what the
+// resolver currently returns.
+func WithBindingStore(store BindingStore) SwitcherOption {
+	return func(o *switcherOptions) { o.bindings = store }
+}
+
+// GatewayListResolver returns gateway names in order of preference. It is used
+// for CreateOrder failover: when a gateway fails with a retriable error the
+// next one in the list is tried.
+type GatewayListResolver func(ctx context.Context) ([]string, error)
+
+// ResolverWithFallbacks builds a GatewayListResolver that prefers the gateway
+// returned by resolver and then tries fallbacks in the given order.
+func ResolverWithFallbacks(resolver GatewayResolver, fallbacks ...string) GatewayListResolver {
+	return func(ctx context.Context) ([]string, error) {
+		primary, err := resolver(ctx)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{primary}
+		for _, name := range fallbacks {
+			if name != primary {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+}
+
+// WithFailover makes DynamicPaymentSwitcher.CreateOrder try the gateways
+// returned by list in order, moving on to the next one when a gateway fails
+// with an error for which IsRetriable is true or which wraps
+// ErrNotImplemented, e.g. an AuthorizeOnly order on a gateway without manual
+// capture. Other errors, such as an invalid request, are returned immediately.
+func WithFailover(list GatewayListResolver) SwitcherOption {
+	return func(o *switcherOptions) { o.failover = list }
+}
+
 // --- DynamicPaymentSwitcher ---
 
 // DynamicPaymentSwitcher resolves the active PaymentGateway at request time.
 // It implements PaymentGateway and delegates all calls to the resolved adapter.
 type DynamicPaymentSwitcher struct {
-	gateways map[string]PaymentGateway
+	gateways *registry[PaymentGateway]
 	resolver GatewayResolver
+	opts     switcherOptions
 }
 
-// NewDynamicPaymentSwitcher creates a DynamicPaymentSwitcher.
-func NewDynamicPaymentSwitcher(gateways map[string]PaymentGateway, resolver GatewayResolver) *DynamicPaymentSwitcher {
-	return &DynamicPaymentSwitcher{gateways: gateways, resolver: resolver}
+// NewDynamicPaymentSwitcher creates a DynamicPaymentSwitcher. The gateways
+// map is copied; use RegisterGateway and friends to change it later.
+func NewDynamicPaymentSwitcher(gateways map[string]PaymentGateway, resolver GatewayResolver, opts ...SwitcherOption) *DynamicPaymentSwitcher {
+	return &DynamicPaymentSwitcher{gateways: newRegistry(gateways), resolver: resolver, opts: newSwitcherOptions(opts)}
 }
 
-func (s *DynamicPaymentSwitcher) resolve(ctx context.Context) (PaymentGateway, error) {
+func (s *DynamicPaymentSwitcher) resolve(ctx context.Context) (string, PaymentGateway, error) {
 	name, err := s.resolver(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("pg-switcher: resolver error: %w", err)
+		return "", nil, fmt.Errorf("pg-switcher: resolver error: %w", err)
+	}
+	gw, ok := s.gateways.get(name)
+	if !ok {
+		return "", nil, fmt.Errorf("pg-switcher: payment gateway %q not registered", name)
+	}
+	s.opts.resolved(ctx, name)
+	return name, gw, nil
+}
+
+// route returns the gateway bound to id, falling back to the resolver when
+// no binding store is configured or id has not been bound.
+func (s *DynamicPaymentSwitcher) route(ctx context.Context, kind BindingKind, id string) (string, PaymentGateway, error) {
+	name, gw, found, err := s.lookup(ctx, kind, id)
+	if err != nil || found {
+		return name, gw, err
+	}
+	return s.resolve(ctx)
+}
+
+// lookup returns the gateway id is bound to, and false when there is no
+// binding store, id is empty or it has no binding.
+func (s *DynamicPaymentSwitcher) lookup(ctx context.Context, kind BindingKind, id string) (string, PaymentGateway, bool, error) {
+	if s.opts.bindings == nil || id == "" {
+		return "", nil, false, nil
+	}
+	name, found, err := s.opts.bindings.Lookup(ctx, kind, id)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("pg-switcher: lookup %s %q: %w", kind, id, err)
+	}
+	if !found {
+		return "", nil, false, nil
 	}
-	gw, ok := s.gateways[name]
+	gw, ok := s.gateways.get(name)
 	if !ok {
-		return nil, fmt.Errorf("pg-switcher: payment gateway %q not registered", name)
+		return "", nil, false, fmt.Errorf("pg-switcher: %s %q is bound to unregistered payment gateway %q", kind, id, name)
+	}
+	return name, gw, true, nil
+}
+
+// bind records id against the named gateway when a binding store is configured.
+func (s *DynamicPaymentSwitcher) bind(ctx context.Context, kind BindingKind, id, name string) error {
+	if s.opts.bindings == nil || id == "" {
+		return nil
 	}
-	return gw, nil
+	if err := s.opts.bindings.Bind(ctx, kind, id, name); err != nil {
+		return fmt.Errorf("pg-switcher: bind %s %q to %q: %w", kind, id, name, err)
+	}
+	return nil
 }
 
 func (s *DynamicPaymentSwitcher) Name() string { return "dynamic" }
 
+// CreateOrder creates the order on the active gateway, or on the first healthy
+// gateway in the failover list when WithFailover is set. The request is
+// available to resolvers through OrderRequestFromContext. The response's
+// Gateway field names the gateway that created the order. AuthorizeOnly
+// orders fail with ErrNotImplemented, before reaching the gateway, when it
+// does not implement PaymentCapturer.
 func (s *DynamicPaymentSwitcher) CreateOrder(ctx context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
-	gw, err := s.resolve(ctx)
+	ctx = withOrderRequest(ctx, req)
+	if s.opts.failover != nil {
+		return s.createOrderWithFailover(ctx, req)
+	}
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return gw.CreateOrder(ctx, req)
+	return s.createOrderOn(ctx, name, gw, req)
+}
+
+func (s *DynamicPaymentSwitcher) createOrderOn(ctx context.Context, name string, gw PaymentGateway, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	// An adapter unaware of AuthorizeOnly would silently capture the payment
+	if req.AuthorizeOnly {
+		if _, err := paymentCapturer(name, gw); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := call(ctx, &s.opts, name, OpCreateOrder, req, gw.CreateOrder)
+	if err != nil {
+		return nil, err
+	}
+	resp.Gateway = name
+	if err := s.bind(ctx, BindingOrder, resp.GatewayOrderID, name); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *DynamicPaymentSwitcher) createOrderWithFailover(ctx context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	names, err := s.opts.failover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pg-switcher: resolver error: %w", err)
+	}
+	var errs []error
+	for _, name := range names {
+		gw, ok := s.gateways.get(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("pg-switcher: payment gateway %q not registered", name))
+			continue
+		}
+		resp, err := s.createOrderOn(ctx, name, gw, req)
+		if err == nil {
+			s.opts.resolved(ctx, name)
+			return resp, nil
+		}
+		if !IsRetriable(err) && !errors.Is(err, ErrNotImplemented) {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("pg-switcher: failover resolver returned no payment gateways")
+	}
+	return nil, fmt.Errorf("pg-switcher: all payment gateways failed: %w", errors.Join(errs...))
 }
 
 func (s *DynamicPaymentSwitcher) VerifyPayment(ctx context.Context, req VerifyPaymentRequest) (bool, error) {
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.route(ctx, BindingOrder, req.GatewayOrderID)
 	if err != nil {
 		return false, err
 	}
-	return gw.VerifyPayment(ctx, req)
+	ok, err := call(ctx, &s.opts, name, OpVerifyPayment, req, gw.VerifyPayment)
+	if err != nil || !ok {
+		return ok, err
+	}
+	if err := s.bind(ctx, BindingPayment, req.GatewayPaymentID, name); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (s *DynamicPaymentSwitcher) GetPaymentStatus(ctx context.Context, gatewayOrderID string) (*PaymentStatus, error) {
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.route(ctx, BindingOrder, gatewayOrderID)
 	if err != nil {
 		return nil, err
 	}
-	return gw.GetPaymentStatus(ctx, gatewayOrderID)
+	status, err := call(ctx, &s.opts, name, OpGetPaymentStatus, gatewayOrderID, gw.GetPaymentStatus)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.bind(ctx, BindingPayment, status.GatewayPaymentID, name); err != nil {
+		return nil, err
+	}
+	return status, nil
 }
 
 func (s *DynamicPaymentSwitcher) InitiateRefund(ctx context.Context, req RefundRequest) (*RefundResponse, error) {
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.routePayment(ctx, req.GatewayPaymentID, req.GatewayOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return call(ctx, &s.opts, name, OpInitiateRefund, req, gw.InitiateRefund)
+}
+
+// GetRefundStatus fetches a refund from the gateway bound to its payment or,
+// failing that, its order, e.g. from a reconciliation job. It fails with
+// ErrNotImplemented when that gateway does not implement RefundQuerier.
+func (s *DynamicPaymentSwitcher) GetRefundStatus(ctx context.Context, req RefundLookup) (*Refund, error) {
+	name, gw, err := s.routePayment(ctx, req.GatewayPaymentID, req.GatewayOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return call(ctx, &s.opts, name, OpGetRefundStatus, req, getRefundStatusFunc(gw, name))
+}
+
+// ListRefunds lists a payment's refunds, routed like GetRefundStatus.
+func (s *DynamicPaymentSwitcher) ListRefunds(ctx context.Context, req RefundLookup) ([]Refund, error) {
+	name, gw, err := s.routePayment(ctx, req.GatewayPaymentID, req.GatewayOrderID)
 	if err != nil {
 		return nil, err
 	}
-	return gw.InitiateRefund(ctx, req)
+	return call(ctx, &s.opts, name, OpListRefunds, req, listRefundsFunc(gw, name))
+}
+
+// CapturePayment captures an authorized payment on the gateway bound to the
+// payment or, failing that, its order. It fails with ErrNotImplemented when
+// that gateway does not implement PaymentCapturer.
+func (s *DynamicPaymentSwitcher) CapturePayment(ctx context.Context, req CaptureRequest) (*PaymentStatus, error) {
+	name, gw, err := s.routePayment(ctx, req.GatewayPaymentID, req.GatewayOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return call(ctx, &s.opts, name, OpCapturePayment, req, capturePaymentFunc(gw, name))
+}
+
+// VoidPayment releases an authorized payment, routed like CapturePayment.
+func (s *DynamicPaymentSwitcher) VoidPayment(ctx context.Context, req VoidRequest) (*PaymentStatus, error) {
+	name, gw, err := s.routePayment(ctx, req.GatewayPaymentID, req.GatewayOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return call(ctx, &s.opts, name, OpVoidPayment, req, voidPaymentFunc(gw, name))
+}
+
+// routePayment routes by the payment's binding or, when the payment has none
+// (e.g. it was never seen by GetPaymentStatus or a webhook), by the order's.
+func (s *DynamicPaymentSwitcher) routePayment(ctx context.Context, paymentID, orderID string) (string, PaymentGateway, error) {
+	name, gw, found, err := s.lookup(ctx, BindingPayment, paymentID)
+	if err != nil || found {
+		return name, gw, err
+	}
+	return s.route(ctx, BindingOrder, orderID)
 }
 
 func (s *DynamicPaymentSwitcher) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
 	// For webhook verification we try all registered gateways — the request context
 	// is not available in webhook handlers that don't know the gateway yet.
 	// The first gateway whose signature verification passes wins.
-	for _, gw := range s.gateways {
-		if gw.VerifyWebhookSignature(payload, headers) {
+	for name, gw := range s.gateways.snapshot() {
+		if _, ok := verifyWebhook(context.Background(), &s.opts, name, gw, payload, headers); ok {
 			return true
 		}
 	}
 	return false
 }
 
+// VerifyAndParseWebhook finds the registered gateway whose signature check
+// accepts the payload and parses the event with that same gateway. Gateways
+// are tried in name order so the result does not depend on map iteration.
+// When a binding store is configured, the order and payment IDs in the event
+// are bound to the verifying gateway.
+func (s *DynamicPaymentSwitcher) VerifyAndParseWebhook(ctx context.Context, payload []byte, headers map[string]string) (*VerifiedWebhook, error) {
+	gateways := s.gateways.snapshot()
+	for _, name := range sortedKeys(gateways) {
+		gw := gateways[name]
+		secretID, ok := verifyWebhook(ctx, &s.opts, name, gw, payload, headers)
+		if !ok {
+			continue
+		}
+		evt, err := s.parseWebhook(ctx, name, gw, payload)
+		if err != nil {
+			return nil, fmt.Errorf("pg-switcher: %s webhook: %w", name, err)
+		}
+		if err := s.bind(ctx, BindingOrder, evt.GatewayOrderID, name); err != nil {
+			return nil, err
+		}
+		if err := s.bind(ctx, BindingPayment, evt.GatewayPaymentID, name); err != nil {
+			return nil, err
+		}
+		return &VerifiedWebhook{Gateway: name, SecretID: secretID, Event: evt}, nil
+	}
+	return nil, ErrWebhookSignatureMismatch
+}
+
+// ParseWebhookEvent parses with the active gateway, which may not be the one
+// that sent the webhook. Prefer VerifyAndParseWebhook.
 func (s *DynamicPaymentSwitcher) ParseWebhookEvent(payload []byte) (*WebhookEvent, error) {
 	// Use background context since this is called from a webhook handler
 	ctx := context.Background()
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		// Fallback: try each gateway
-		for _, gw := range s.gateways {
-			evt, err := gw.ParseWebhookEvent(payload)
+		for name, gw := range s.gateways.snapshot() {
+			evt, err := s.parseWebhook(ctx, name, gw, payload)
 			if err == nil {
 				return evt, nil
 			}
 		}
 		return nil, fmt.Errorf("pg-switcher: unable to parse webhook event: %w", err)
 	}
-	return gw.ParseWebhookEvent(payload)
+	return s.parseWebhook(ctx, name, gw, payload)
+}
+
+func (s *DynamicPaymentSwitcher) parseWebhook(ctx context.Context, name string, gw PaymentGateway, payload []byte) (*WebhookEvent, error) {
+	return intercept(ctx, &s.opts, name, OpParseWebhookEvent, WebhookPayload{Payload: payload},
+		func(_ context.Context, p WebhookPayload) (*WebhookEvent, error) {
+			return gw.ParseWebhookEvent(p.Payload)
+		})
 }
 
 func (s *DynamicPaymentSwitcher) ClientCredentials() map[string]interface{} {
 	ctx := context.Background()
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		return map[string]interface{}{}
 	}
-	return gw.ClientCredentials()
+	creds, _ := intercept(ctx, &s.opts, name, OpClientCredentials, nil, clientCredentialsFunc(gw))
+	return creds
 }
 
 // ActiveGatewayName resolves and returns the name of the currently active payment gateway.
 func (s *DynamicPaymentSwitcher) ActiveGatewayName(ctx context.Context) (string, error) {
-	gw, err := s.resolve(ctx)
+	_, gw, err := s.resolve(ctx)
 	if err != nil {
 		return "", err
 	}
 	return gw.Name(), nil
 }
 
+// Health returns the circuit breaker state of every registered gateway, sorted
+// by name. It returns nil unless WithCircuitBreaker was given.
+func (s *DynamicPaymentSwitcher) Health() []GatewayHealth {
+	return s.opts.health(s.gateways.names())
+}
+
+// CheckHealth runs the health check of every registered gateway concurrently,
+// e.g. for a readiness probe. Results are sorted by gateway name; adapters
+// without a health check are reported as Skipped.
+func (s *DynamicPaymentSwitcher) CheckHealth(ctx context.Context) []HealthCheckResult {
+	gateways := s.gateways.snapshot()
+	return checkAll(ctx, sortedKeys(gateways), gateways)
+}
+
+// GatewayNames returns the sorted names of the registered payment gateways.
+func (s *DynamicPaymentSwitcher) GatewayNames() []string {
+	return s.gateways.names()
+}
+
+// RegisterGateway adds a payment gateway under name. It fails with
+// ErrGatewayRegistered if the name is taken.
+func (s *DynamicPaymentSwitcher) RegisterGateway(name string, gw PaymentGateway) error {
+	return s.gateways.register(name, gw)
+}
+
+// ReplaceGateway atomically swaps the adapter registered under name, e.g. for
+// one with fresh credentials, and resets its circuit breaker. Calls already
+// running on the old adapter finish on it. It fails with
+// ErrGatewayNotRegistered if name is unknown.
+func (s *DynamicPaymentSwitcher) ReplaceGateway(name string, gw PaymentGateway) error {
+	if err := s.gateways.replace(name, gw); err != nil {
+		return err
+	}
+	s.opts.resetBreaker(name)
+	return nil
+}
+
+// UnregisterGateway removes the adapter registered under name. Calls already
+// running on it finish normally; later calls resolved or bound to name fail
+// as unregistered. It fails with ErrGatewayNotRegistered if name is unknown.
+func (s *DynamicPaymentSwitcher) UnregisterGateway(name string) error {
+	if err := s.gateways.unregister(name); err != nil {
+		return err
+	}
+	s.opts.resetBreaker(name)
+	return nil
+}
+
 // --- DynamicPayoutSwitcher ---
 
 // DynamicPayoutSwitcher resolves the active PayoutGateway at request time.
 type DynamicPayoutSwitcher struct {
-	gateways map[string]PayoutGateway
+	gateways *registry[PayoutGateway]
 	resolver GatewayResolver
+	opts     switcherOptions
 }
 
-// NewDynamicPayoutSwitcher creates a DynamicPayoutSwitcher.
-func NewDynamicPayoutSwitcher(gateways map[string]PayoutGateway, resolver GatewayResolver) *DynamicPayoutSwitcher {
-	return &DynamicPayoutSwitcher{gateways: gateways, resolver: resolver}
+// NewDynamicPayoutSwitcher creates a DynamicPayoutSwitcher. The gateways map
+// is copied; use RegisterGateway and friends to change it later. Options that
+// only concern payments, such as WithBindingStore and WithFailover, are ignored.
+func NewDynamicPayoutSwitcher(gateways map[string]PayoutGateway, resolver GatewayResolver, opts ...SwitcherOption) *DynamicPayoutSwitcher {
+	return &DynamicPayoutSwitcher{gateways: newRegistry(gateways), resolver: resolver, opts: newSwitcherOptions(opts)}
 }
 
-func (s *DynamicPayoutSwitcher) resolve(ctx context.Context) (PayoutGateway, error) {
+func (s *DynamicPayoutSwitcher) resolve(ctx context.Context) (string, PayoutGateway, error) {
 	name, err := s.resolver(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("pg-switcher: resolver error: %w", err)
+		return "", nil, fmt.Errorf("pg-switcher: resolver error: %w", err)
 	}
-	gw, ok := s.gateways[name]
+	gw, ok := s.gateways.get(name)
 	if !ok {
-		return nil, fmt.Errorf("pg-switcher: payout gateway %q not registered", name)
+		return "", nil, fmt.Errorf("pg-switcher: payout gateway %q not registered", name)
 	}
-	return gw, nil
+	s.opts.resolved(ctx, name)
+	return name, gw, nil
 }
 
 func (s *DynamicPayoutSwitcher) Name() string { return "dynamic" }
 
 func (s *DynamicPayoutSwitcher) CreateContact(ctx context.Context, req CreateContactRequest) (*ContactResponse, error) {
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return gw.CreateContact(ctx, req)
+	return call(ctx, &s.opts, name, OpCreateContact, req, gw.CreateContact)
 }
 
 func (s *DynamicPayoutSwitcher) UpdateContact(ctx context.Context, contactID string, req CreateContactRequest) (*ContactResponse, error) {
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return gw.UpdateContact(ctx, contactID, req)
+	return call(ctx, &s.opts, name, OpUpdateContact, UpdateContactCall{contactID, req}, updateContactFunc(gw))
 }
 
 func (s *DynamicPayoutSwitcher) CreateFundAccount(ctx context.Context, req CreateFundAccountRequest) (*FundAccountResponse, error) {
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return gw.CreateFundAccount(ctx, req)
+	return call(ctx, &s.opts, name, OpCreateFundAccount, req, gw.CreateFundAccount)
 }
 
 func (s *DynamicPayoutSwitcher) InitiatePayout(ctx context.Context, req InitiatePayoutRequest) (*PayoutResponse, error) {
-	gw, err := s.resolve(ctx)
+	ctx = withPayoutRequest(ctx, req)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return gw.InitiatePayout(ctx, req)
+	return call(ctx, &s.opts, name, OpInitiatePayout, req, gw.InitiatePayout)
 }
 
 func (s *DynamicPayoutSwitcher) GetPayoutStatus(ctx context.Context, gatewayPayoutID string) (*PayoutStatusResponse, error) {
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return gw.GetPayoutStatus(ctx, gatewayPayoutID)
+	return call(ctx, &s.opts, name, OpGetPayoutStatus, gatewayPayoutID, gw.GetPayoutStatus)
 }
 
 func (s *DynamicPayoutSwitcher) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
-	for _, gw := range s.gateways {
-		if gw.VerifyWebhookSignature(payload, headers) {
+	for name, gw := range s.gateways.snapshot() {
+		if _, ok := verifyWebhook(context.Background(), &s.opts, name, gw, payload, headers); ok {
 			return true
 		}
 	}
 	return false
 }
 
+// VerifyAndParseWebhook finds the registered gateway whose signature check
+// accepts the payload and parses the event with that same gateway.
+func (s *DynamicPayoutSwitcher) VerifyAndParseWebhook(ctx context.Context, payload []byte, headers map[string]string) (*VerifiedPayoutWebhook, error) {
+	gateways := s.gateways.snapshot()
+	for _, name := range sortedKeys(gateways) {
+		gw := gateways[name]
+		secretID, ok := verifyWebhook(ctx, &s.opts, name, gw, payload, headers)
+		if !ok {
+			continue
+		}
+		evt, err := s.parseWebhook(ctx, name, gw, payload)
+		if err != nil {
+			return nil, fmt.Errorf("pg-switcher: %s payout webhook: %w", name, err)
+		}
+		return &VerifiedPayoutWebhook{Gateway: name, SecretID: secretID, Event: evt}, nil
+	}
+	return nil, ErrWebhookSignatureMismatch
+}
+
+// ParseWebhookEvent parses with the active gateway, which may not be the one
+// that sent the webhook. Prefer VerifyAndParseWebhook.
 func (s *DynamicPayoutSwitcher) ParseWebhookEvent(payload []byte) (*PayoutWebhookEvent, error) {
 	ctx := context.Background()
-	gw, err := s.resolve(ctx)
+	name, gw, err := s.resolve(ctx)
 	if err != nil {
-		for _, gw := range s.gateways {
-			evt, err := gw.ParseWebhookEvent(payload)
+		for name, gw := range s.gateways.snapshot() {
+			evt, err := s.parseWebhook(ctx, name, gw, payload)
 			if err == nil {
 				return evt, nil
 			}
 		}
 		return nil, fmt.Errorf("pg-switcher: unable to parse payout webhook event: %w", err)
 	}
-	return gw.ParseWebhookEvent(payload)
+	return s.parseWebhook(ctx, name, gw, payload)
+}
+
+func (s *DynamicPayoutSwitcher) parseWebhook(ctx context.Context, name string, gw PayoutGateway, payload []byte) (*PayoutWebhookEvent, error) {
+	return intercept(ctx, &s.opts, name, OpParseWebhookEvent, WebhookPayload{Payload: payload},
+		func(_ context.Context, p WebhookPayload) (*PayoutWebhookEvent, error) {
+			return gw.ParseWebhookEvent(p.Payload)
+		})
 }
 
 func (s *DynamicPayoutSwitcher) IsManual() bool {
 	ctx := context.Background()
-	gw, err := s.resolve(ctx)
+	_, gw, err := s.resolve(ctx)
 	if err != nil {
 		return false
 	}
@@ -218,9 +647,67 @@ func (s *DynamicPayoutSwitcher) IsManual() bool {
 
 // ActiveGatewayName resolves and returns the name of the currently active payout gateway.
 func (s *DynamicPayoutSwitcher) ActiveGatewayName(ctx context.Context) (string, error) {
-	gw, err := s.resolve(ctx)
+	_, gw, err := s.resolve(ctx)
 	if err != nil {
 		return "", err
 	}
 	return gw.Name(), nil
 }
+
+// Health returns the circuit breaker state of every registered gateway, sorted
+// by name. It returns nil unless WithCircuitBreaker was given.
+func (s *DynamicPayoutSwitcher) Health() []GatewayHealth {
+	return s.opts.health(s.gateways.names())
+}
+
+// CheckHealth runs the health check of every registered gateway concurrently,
+// e.g. for a readiness probe. Results are sorted by gateway name; adapters
+// without a health check are reported as Skipped.
+func (s *DynamicPayoutSwitcher) CheckHealth(ctx context.Context) []HealthCheckResult {
+	gateways := s.gateways.snapshot()
+	return checkAll(ctx, sortedKeys(gateways), gateways)
+}
+
+// GatewayNames returns the sorted names of the registered payout gateways.
+func (s *DynamicPayoutSwitcher) GatewayNames() []string {
+	return s.gateways.names()
+}
+
+// RegisterGateway adds a payout gateway under name. It fails with
+// ErrGatewayRegistered if the name is taken.
+func (s *DynamicPayoutSwitcher) RegisterGateway(name string, gw PayoutGateway) error {
+	return s.gateways.register(name, gw)
+}
+
+// ReplaceGateway atomically swaps the adapter registered under name, e.g. for
+// one with fresh credentials, and resets its circuit breaker. Calls already
+// running on the old adapter finish on it. It fails with
+// ErrGatewayNotRegistered if name is unknown.
+func (s *DynamicPayoutSwitcher) ReplaceGateway(name string, gw PayoutGateway) error {
+	if err := s.gateways.replace(name, gw); err != nil {
+		return err
+	}
+	s.opts.resetBreaker(name)
+	return nil
+}
+
+// UnregisterGateway removes the adapter registered under name. Calls already
+// running on it finish normally; later calls resolved or bound to name fail
+// as unregistered. It fails with ErrGatewayNotRegistered if name is unknown.
+func (s *DynamicPayoutSwitcher) UnregisterGateway(name string) error {
+	if err := s.gateways.unregister(name); err != nil {
+		return err
+	}
+	s.opts.resetBreaker(name)
+	return nil
+}
+
+// sortedKeys returns the keys of a gateway map in ascending order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,351 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// stubGateway answers every call with IDs derived from its name, failing
+// CreateOrder with createErr and the first GetPaymentStatus calls with
+// statusErrs.
+type stubGateway struct {
+	orderGateway
+	name        string
+	createErr   error
+	statusErrs  []error
+	statusCalls atomic.Int32
+	refunds     atomic.Int32
+}
+
+func (g *stubGateway) Name() string { return g.name }
+
+func (g *stubGateway) CreateOrder(context.Context, CreateOrderRequest) (*CreateOrderResponse, error) {
+	g.calls.Add(1)
+	if g.createErr != nil {
+		return nil, g.createErr
+	}
+	return &CreateOrderResponse{GatewayOrderID: "order_" + g.name}, nil
+}
+
+func (g *stubGateway) VerifyPayment(context.Context, VerifyPaymentRequest) (bool, error) {
+	return true, nil
+}
+
+func (g *stubGateway) GetPaymentStatus(_ context.Context, orderID string) (*PaymentStatus, error) {
+	if n := int(g.statusCalls.Add(1)); n <= len(g.statusErrs) {
+		return nil, g.statusErrs[n-1]
+	}
+	return &PaymentStatus{GatewayOrderID: orderID, GatewayPaymentID: "pay_" + g.name, State: PaymentStateCaptured}, nil
+}
+
+func (g *stubGateway) InitiateRefund(context.Context, RefundRequest) (*RefundResponse, error) {
+	g.refunds.Add(1)
+	return &RefundResponse{RefundID: "rfnd_" + g.name}, nil
+}
+
+// switchable is a resolver whose choice can be changed between calls.
+type switchable struct{ name atomic.Value }
+
+func newSwitchable(name string) *switchable {
+	s := &switchable{}
+	s.name.Store(name)
+	return s
+}
+
+func (s *switchable) resolve(context.Context) (string, error) { return s.name.Load().(string), nil }
+
+func newStubs(names ...string) map[string]PaymentGateway {
+	gateways := make(map[string]PaymentGateway, len(names))
+	for _, name := range names {
+		gateways[name] = &stubGateway{name: name}
+	}
+	return gateways
+}
+
+func TestStickyRouting(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func(s *DynamicPaymentSwitcher) (string, error) // returns an ID naming the gateway used
+		want string
+	}{
+		{
+			name: "status of a bound order",
+			call: func(s *DynamicPaymentSwitcher) (string, error) {
+				st, err := s.GetPaymentStatus(ctx, "order_paytm")
+				if err != nil {
+					return "", err
+				}
+				return st.GatewayPaymentID, nil
+			},
+			want: "pay_paytm",
+		},
+		{
+			name: "status of an unbound order",
+			call: func(s *DynamicPaymentSwitcher) (string, error) {
+				st, err := s.GetPaymentStatus(ctx, "order_elsewhere")
+				if err != nil {
+					return "", err
+				}
+				return st.GatewayPaymentID, nil
+			},
+			want: "pay_razorpay",
+		},
+		{
+			name: "refund of a payment bound by GetPaymentStatus",
+			call: func(s *DynamicPaymentSwitcher) (string, error) {
+				if _, err := s.GetPaymentStatus(ctx, "order_paytm"); err != nil {
+					return "", err
+				}
+				r, err := s.InitiateRefund(ctx, RefundRequest{GatewayPaymentID: "pay_paytm"})
+				if err != nil {
+					return "", err
+				}
+				return r.RefundID, nil
+			},
+			want: "rfnd_paytm",
+		},
+		{
+			name: "refund of an unbound payment",
+			call: func(s *DynamicPaymentSwitcher) (string, error) {
+				r, err := s.InitiateRefund(ctx, RefundRequest{GatewayPaymentID: "pay_elsewhere"})
+				if err != nil {
+					return "", err
+				}
+				return r.RefundID, nil
+			},
+			want: "rfnd_razorpay",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := newSwitchable("paytm")
+			s := NewDynamicPaymentSwitcher(newStubs("paytm", "razorpay"), resolver.resolve, WithBindingStore(NewMemoryBindingStore()))
+			order, err := s.CreateOrder(ctx, CreateOrderRequest{Amount: 100, Currency: "INR"})
+			if err != nil || order.Gateway != "paytm" {
+				t.Fatalf("CreateOrder = %+v, %v; want an order on paytm", order, err)
+			}
+			resolver.name.Store("razorpay") // an admin switches the active gateway
+			got, err := tt.call(s)
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStickyRoutingErrors(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryBindingStore()
+	store.Bind(ctx, BindingOrder, "order_gone", "removed")
+	s := NewDynamicPaymentSwitcher(newStubs("razorpay"), StaticResolver("razorpay"), WithBindingStore(store))
+
+	_, err := s.GetPaymentStatus(ctx, "order_gone")
+	if err == nil || !strings.Contains(err.Error(), `unregistered payment gateway "removed"`) {
+		t.Fatalf("GetPaymentStatus of an order bound to a removed gateway = %v", err)
+	}
+
+	s = NewDynamicPaymentSwitcher(newStubs("razorpay"), StaticResolver("razorpay"), WithBindingStore(failingBindings{}))
+	if _, err := s.CreateOrder(ctx, CreateOrderRequest{}); !errors.Is(err, errBindingsDown) {
+		t.Fatalf("CreateOrder with a failing binding store = %v, want errBindingsDown", err)
+	}
+	if _, err := s.GetPaymentStatus(ctx, "order_razorpay"); !errors.Is(err, errBindingsDown) {
+		t.Fatalf("GetPaymentStatus with a failing binding store = %v, want errBindingsDown", err)
+	}
+}
+
+var errBindingsDown = errors.New("bindings down")
+
+type failingBindings struct{}
+
+func (failingBindings) Bind(context.Context, BindingKind, string, string) error {
+	return errBindingsDown
+}
+
+func (failingBindings) Lookup(context.Context, BindingKind, string) (string, bool, error) {
+	return "", false, errBindingsDown
+}
+
+func TestFailover(t *testing.T) {
+	tests := []struct {
+		name      string
+		list      []string
+		createErr map[string]error
+		open      string // gateway whose breaker is open
+		want      string // gateway that creates the order; "" expects an error
+		wantErr   error
+		wantCalls map[string]int32
+	}{
+		{
+			name:      "first gateway",
+			list:      []string{"a", "b"},
+			want:      "a",
+			wantCalls: map[string]int32{"a": 1, "b": 0},
+		},
+		{
+			name:      "skips a failing gateway",
+			list:      []string{"a", "b"},
+			createErr: map[string]error{"a": errUnavailable},
+			want:      "b",
+			wantCalls: map[string]int32{"a": 1, "b": 1},
+		},
+		{
+			name:      "skips an open gateway without calling it",
+			list:      []string{"a", "b"},
+			open:      "a",
+			want:      "b",
+			wantCalls: map[string]int32{"a": 0, "b": 1},
+		},
+		{
+			name:      "skips an unregistered gateway",
+			list:      []string{"missing", "b"},
+			want:      "b",
+			wantCalls: map[string]int32{"b": 1},
+		},
+		{
+			name:      "stops on a non-retriable error",
+			list:      []string{"a", "b"},
+			createErr: map[string]error{"a": ErrInvalidRequest},
+			wantErr:   ErrInvalidRequest,
+			wantCalls: map[string]int32{"a": 1, "b": 0},
+		},
+		{
+			name:      "all gateways fail",
+			list:      []string{"a", "b"},
+			createErr: map[string]error{"a": errUnavailable, "b": errUnavailable},
+			wantErr:   ErrGatewayUnavailable,
+			wantCalls: map[string]int32{"a": 1, "b": 1},
+		},
+		{
+			name: "empty list",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateways := newStubs("a", "b")
+			for name, err := range tt.createErr {
+				gateways[name].(*stubGateway).createErr = err
+			}
+			var resolved []string
+			s := NewDynamicPaymentSwitcher(gateways, StaticResolver("a"),
+				WithFailover(func(context.Context) ([]string, error) { return tt.list, nil }),
+				WithCircuitBreaker(BreakerConfig{WindowSize: 1, MinRequests: 1}),
+				WithResolveObserver(func(_ context.Context, name string) { resolved = append(resolved, name) }))
+			if tt.open != "" {
+				b := s.opts.breakers.get(tt.open)
+				gen, _ := b.allow()
+				b.record(gen, errUnavailable)
+			}
+
+			order, err := s.CreateOrder(context.Background(), CreateOrderRequest{Amount: 100, Currency: "INR"})
+			if tt.want == "" {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateOrder error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || order.Gateway != tt.want || len(resolved) != 1 || resolved[0] != tt.want {
+				t.Fatalf("CreateOrder = %+v, %v, resolved %v; want an order on %s", order, err, resolved, tt.want)
+			}
+			for name, want := range tt.wantCalls {
+				if got := gateways[name].(*stubGateway).calls.Load(); got != want {
+					t.Errorf("%s called %d times, want %d", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// refundingStub adds refund queries and capture to stubGateway, answering
+// with IDs derived from its name.
+type refundingStub struct{ *stubGateway }
+
+func (g refundingStub) GetRefundStatus(_ context.Context, req RefundLookup) (*Refund, error) {
+	return &Refund{RefundID: "rfnd_" + g.name, GatewayPaymentID: req.GatewayPaymentID}, nil
+}
+
+func (g refundingStub) ListRefunds(ctx context.Context, req RefundLookup) ([]Refund, error) {
+	r, _ := g.GetRefundStatus(ctx, req)
+	return []Refund{*r}, nil
+}
+
+func (g refundingStub) CapturePayment(context.Context, CaptureRequest) (*PaymentStatus, error) {
+	return &PaymentStatus{GatewayPaymentID: "pay_" + g.name, State: PaymentStateCaptured}, nil
+}
+
+func (g refundingStub) VoidPayment(context.Context, VoidRequest) (*PaymentStatus, error) {
+	return &PaymentStatus{GatewayPaymentID: "pay_" + g.name, State: PaymentStateFailed}, nil
+}
+
+func TestRoutePayment(t *testing.T) {
+	ctx := context.Background()
+	// each op returns the name of the gateway that handled it
+	ops := map[string]func(s *DynamicPaymentSwitcher, orderID, paymentID string) (string, error){
+		"InitiateRefund": func(s *DynamicPaymentSwitcher, orderID, paymentID string) (string, error) {
+			r, err := s.InitiateRefund(ctx, RefundRequest{GatewayOrderID: orderID, GatewayPaymentID: paymentID, Amount: 100})
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimPrefix(r.RefundID, "rfnd_"), nil
+		},
+		"GetRefundStatus": func(s *DynamicPaymentSwitcher, orderID, paymentID string) (string, error) {
+			r, err := s.GetRefundStatus(ctx, RefundLookup{GatewayOrderID: orderID, GatewayPaymentID: paymentID, RefundID: "rfnd_1"})
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimPrefix(r.RefundID, "rfnd_"), nil
+		},
+		"ListRefunds": func(s *DynamicPaymentSwitcher, orderID, paymentID string) (string, error) {
+			r, err := s.ListRefunds(ctx, RefundLookup{GatewayOrderID: orderID, GatewayPaymentID: paymentID})
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimPrefix(r[0].RefundID, "rfnd_"), nil
+		},
+		"CapturePayment": func(s *DynamicPaymentSwitcher, orderID, paymentID string) (string, error) {
+			st, err := s.CapturePayment(ctx, CaptureRequest{GatewayOrderID: orderID, GatewayPaymentID: paymentID})
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimPrefix(st.GatewayPaymentID, "pay_"), nil
+		},
+	}
+	tests := []struct {
+		name      string
+		bindOrder string // gateway the order is bound to, if any
+		bindPay   string // gateway the payment is bound to, if any
+		want      string // gateway the call reaches
+	}{
+		{"payment binding wins", "paytm", "payu", "payu"},
+		{"falls back to the order binding", "paytm", "", "paytm"},
+		{"falls back to the resolver", "", "", "razorpay"},
+	}
+	for op, call := range ops {
+		for _, tt := range tests {
+			t.Run(op+"/"+tt.name, func(t *testing.T) {
+				gateways := make(map[string]PaymentGateway)
+				for _, name := range []string{"paytm", "payu", "razorpay"} {
+					gateways[name] = refundingStub{&stubGateway{name: name}}
+				}
+				store := NewMemoryBindingStore()
+				if tt.bindOrder != "" {
+					store.Bind(ctx, BindingOrder, "order_1", tt.bindOrder)
+				}
+				if tt.bindPay != "" {
+					store.Bind(ctx, BindingPayment, "pay_1", tt.bindPay)
+				}
+				s := NewDynamicPaymentSwitcher(gateways, StaticResolver("razorpay"), WithBindingStore(store))
+				got, err := call(s, "order_1", "pay_1")
+				if err != nil || got != tt.want {
+					t.Fatalf("%s reached %q, %v; want %s", op, got, err, tt.want)
+				}
+			})
+		}
+	}
+
+	s := NewDynamicPaymentSwitcher(newStubs("razorpay"), StaticResolver("razorpay"), WithBindingStore(failingBindings{}))
+	if _, err := s.InitiateRefund(ctx, RefundRequest{GatewayOrderID: "order_1", GatewayPaymentID: "pay_1"}); !errors.Is(err, errBindingsDown) {
+		t.Fatalf("InitiateRefund with a failing binding store = %v, want errBindingsDown", err)
+	}
+}
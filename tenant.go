@@ -0,0 +1,518 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNoTenant is returned by the tenant switchers when no tenant could be
+	// determined for a call.
+	ErrNoTenant = errors.New("pg-switcher: no tenant in context")
+
+	// ErrTenantNotFound should be returned (wrapped) by a TenantConfigSource
+	// that does not know a tenant.
+	ErrTenantNotFound = errors.New("pg-switcher: tenant not found")
+)
+
+type tenantCtxKey struct{}
+
+// WithTenant returns a context carrying tenantID (e.g. a brand or merchant
+// ID) for the tenant switchers.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or "".
+func TenantFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantCtxKey{}).(string)
+	return id
+}
+
+// TenantConfigSource supplies the gateway credentials of each tenant.
+// Implementations must be safe for concurrent use.
+type TenantConfigSource interface {
+	// TenantConfig returns the config of tenantID, or an error wrapping
+	// ErrTenantNotFound. Its PaymentGateway and PayoutGateway are the tenant's
+	// default gateways.
+	TenantConfig(ctx context.Context, tenantID string) (Config, error)
+
+	// TenantIDs lists every tenant. It is used to find the tenant whose
+	// webhook secret verifies a webhook that arrives without one.
+	TenantIDs(ctx context.Context) ([]string, error)
+}
+
+// StaticTenantConfigs is a TenantConfigSource backed by a fixed map.
+type StaticTenantConfigs map[string]Config
+
+// TenantConfig implements TenantConfigSource.
+func (s StaticTenantConfigs) TenantConfig(_ context.Context, tenantID string) (Config, error) {
+	cfg, ok := s[tenantID]
+	if !ok {
+		return Config{}, fmt.Errorf("%w: %q", ErrTenantNotFound, tenantID)
+	}
+	return cfg, nil
+}
+
+// TenantIDs implements TenantConfigSource.
+func (s StaticTenantConfigs) TenantIDs(context.Context) ([]string, error) {
+	return sortedKeys(s), nil
+}
+
+// TenantGatewayResolver is called on every operation of a tenant switcher to
+// determine the tenant and the gateway to use. An empty gateway selects the
+// tenant's default gateway from its Config.
+type TenantGatewayResolver func(ctx context.Context) (tenantID, gateway string, err error)
+
+// ContextTenantResolver builds a TenantGatewayResolver that takes the tenant
+// from the context (see WithTenant) and the gateway from resolver. A nil
+// resolver selects the tenant's default gateway.
+func ContextTenantResolver(resolver GatewayResolver) TenantGatewayResolver {
+	return func(ctx context.Context) (string, string, error) {
+		tenantID := TenantFromContext(ctx)
+		if tenantID == "" {
+			return "", "", ErrNoTenant
+		}
+		if resolver == nil {
+			return tenantID, "", nil
+		}
+		gateway, err := resolver(ctx)
+		return tenantID, gateway, err
+	}
+}
+
+type tenantGatewayCtxKey struct{}
+
+// tenantDefaultResolver is the resolver of every per-tenant switcher: the
+// gateway chosen by the TenantGatewayResolver, else the tenant's default.
+func tenantDefaultResolver(name string) GatewayResolver {
+	return func(ctx context.Context) (string, error) {
+		if gw, _ := ctx.Value(tenantGatewayCtxKey{}).(string); gw != "" {
+			return gw, nil
+		}
+		if name == "" {
+			return "", fmt.Errorf("no gateway selected for tenant %q", TenantFromContext(ctx))
+		}
+		return name, nil
+	}
+}
+
+// tenantSet lazily builds one switcher per tenant from the tenant's config
+// and caches it. Configs are loaded without holding the lock; when two calls
+// build the same tenant at once, the first result stored wins.
+type tenantSet[S any] struct {
+	source   TenantConfigSource
+	resolver TenantGatewayResolver
+	build    func(tenantID string, cfg Config) (S, error)
+
+	mu        sync.Mutex
+	switchers map[string]S
+}
+
+func newTenantSet[S any](source TenantConfigSource, resolver TenantGatewayResolver, build func(string, Config) (S, error)) *tenantSet[S] {
+	if resolver == nil {
+		resolver = ContextTenantResolver(nil)
+	}
+	return &tenantSet[S]{source: source, resolver: resolver, build: build, switchers: make(map[string]S)}
+}
+
+func (t *tenantSet[S]) get(ctx context.Context, tenantID string) (S, error) {
+	t.mu.Lock()
+	sw, ok := t.switchers[tenantID]
+	t.mu.Unlock()
+	if ok {
+		return sw, nil
+	}
+
+	var zero S
+	if tenantID == "" {
+		return zero, ErrNoTenant
+	}
+	cfg, err := t.source.TenantConfig(ctx, tenantID)
+	if err != nil {
+		return zero, fmt.Errorf("pg-switcher: tenant %q: %w", tenantID, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return zero, fmt.Errorf("pg-switcher: tenant %q: %w", tenantID, err)
+	}
+	sw, err = t.build(tenantID, cfg)
+	if err != nil {
+		return zero, fmt.Errorf("pg-switcher: tenant %q: %w", tenantID, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cached, ok := t.switchers[tenantID]; ok {
+		return cached, nil
+	}
+	t.switchers[tenantID] = sw
+	return sw, nil
+}
+
+// resolve picks the tenant and gateway for ctx and returns the tenant's
+// switcher with a context that carries both.
+func (t *tenantSet[S]) resolve(ctx context.Context) (context.Context, S, error) {
+	var zero S
+	tenantID, gateway, err := t.resolver(ctx)
+	if err != nil {
+		return ctx, zero, fmt.Errorf("pg-switcher: tenant resolver error: %w", err)
+	}
+	sw, err := t.get(ctx, tenantID)
+	if err != nil {
+		return ctx, zero, err
+	}
+	ctx = WithTenant(ctx, tenantID)
+	return context.WithValue(ctx, tenantGatewayCtxKey{}, gateway), sw, nil
+}
+
+// webhookTenants returns the tenants to try for a webhook: the one in ctx if
+// set (e.g. from a per-tenant webhook URL), else all of them in sorted order.
+func (t *tenantSet[S]) webhookTenants(ctx context.Context) ([]string, error) {
+	if tenantID := TenantFromContext(ctx); tenantID != "" {
+		return []string{tenantID}, nil
+	}
+	ids, err := t.source.TenantIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pg-switcher: list tenants: %w", err)
+	}
+	ids = append([]string(nil), ids...)
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// webhookMismatch returns ErrWebhookSignatureMismatch, joined with the
+// errors of tenants that were skipped because their switcher could not be built.
+func webhookMismatch(skipped []error) error {
+	if len(skipped) == 0 {
+		return ErrWebhookSignatureMismatch
+	}
+	return fmt.Errorf("%w; skipped tenants: %w", ErrWebhookSignatureMismatch, errors.Join(skipped...))
+}
+
+// invalidate drops the cached switcher of tenantID.
+func (t *tenantSet[S]) invalidate(tenantID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.switchers, tenantID)
+}
+
+// tenantBindings is the view of a shared BindingStore used by one tenant. IDs
+// are stored as "<escaped tenant ID>/<id>".
+type tenantBindings struct {
+	store    BindingStore
+	tenantID string
+}
+
+func (b tenantBindings) id(id string) string { return url.PathEscape(b.tenantID) + "/" + id }
+
+func (b tenantBindings) Bind(ctx context.Context, kind BindingKind, id, gateway string) error {
+	return b.store.Bind(ctx, kind, b.id(id), gateway)
+}
+
+func (b tenantBindings) Lookup(ctx context.Context, kind BindingKind, id string) (string, bool, error) {
+	return b.store.Lookup(ctx, kind, b.id(id))
+}
+
+// withTenantBindings scopes the binding store set by earlier options, if any,
+// to tenantID. It must come after them.
+func withTenantBindings(tenantID string) SwitcherOption {
+	return func(o *switcherOptions) {
+		if o.bindings != nil {
+			o.bindings = tenantBindings{store: o.bindings, tenantID: tenantID}
+		}
+	}
+}
+
+// --- TenantPaymentSwitcher ---
+
+// TenantPaymentSwitcher routes payment calls to a per-tenant
+// DynamicPaymentSwitcher, so that each tenant uses its own credentials.
+// Tenant switchers are built on first use from the TenantConfigSource and
+// cached until Invalidate is called.
+//
+// Webhooks are verified against each tenant's secrets in turn and routed to
+// the tenant whose secret matched. Methods of PaymentGateway without a
+// context (ClientCredentials, ParseWebhookEvent) cannot pick a tenant; use
+// Switcher for those.
+type TenantPaymentSwitcher struct {
+	tenants *tenantSet[*DynamicPaymentSwitcher]
+}
+
+// NewTenantPaymentSwitcher creates a TenantPaymentSwitcher. A nil resolver
+// takes the tenant from the context and uses the tenant's PaymentGateway.
+// The options are applied to every per-tenant switcher; circuit breakers
+// and retries are therefore tracked per tenant and gateway. A store passed
+// with WithBindingStore is shared, with each tenant's IDs kept apart, since
+// e.g. Paytm order IDs are merchant receipts that tenants may reuse.
+func NewTenantPaymentSwitcher(source TenantConfigSource, resolver TenantGatewayResolver, opts ...SwitcherOption) *TenantPaymentSwitcher {
+	build := func(tenantID string, cfg Config) (*DynamicPaymentSwitcher, error) {
+		opts := append(opts[:len(opts):len(opts)], withTenantBindings(tenantID))
+		return NewDynamicPaymentSwitcherFromConfig(cfg, tenantDefaultResolver(cfg.PaymentGateway), opts...)
+	}
+	return &TenantPaymentSwitcher{tenants: newTenantSet(source, resolver, build)}
+}
+
+// Switcher returns the switcher of tenantID, building it if needed.
+func (s *TenantPaymentSwitcher) Switcher(ctx context.Context, tenantID string) (*DynamicPaymentSwitcher, error) {
+	return s.tenants.get(ctx, tenantID)
+}
+
+// Invalidate drops the cached switcher of tenantID, so that its next call
+// reloads the tenant's config, e.g. after its credentials changed.
+func (s *TenantPaymentSwitcher) Invalidate(tenantID string) {
+	s.tenants.invalidate(tenantID)
+}
+
+func (s *TenantPaymentSwitcher) Name() string { return "tenant" }
+
+func (s *TenantPaymentSwitcher) CreateOrder(ctx context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.CreateOrder(ctx, req)
+}
+
+func (s *TenantPaymentSwitcher) VerifyPayment(ctx context.Context, req VerifyPaymentRequest) (bool, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	return sw.VerifyPayment(ctx, req)
+}
+
+func (s *TenantPaymentSwitcher) GetPaymentStatus(ctx context.Context, gatewayOrderID string) (*PaymentStatus, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.GetPaymentStatus(ctx, gatewayOrderID)
+}
+
+func (s *TenantPaymentSwitcher) InitiateRefund(ctx context.Context, req RefundRequest) (*RefundResponse, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.InitiateRefund(ctx, req)
+}
+
+func (s *TenantPaymentSwitcher) GetRefundStatus(ctx context.Context, req RefundLookup) (*Refund, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.GetRefundStatus(ctx, req)
+}
+
+func (s *TenantPaymentSwitcher) ListRefunds(ctx context.Context, req RefundLookup) ([]Refund, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.ListRefunds(ctx, req)
+}
+
+func (s *TenantPaymentSwitcher) CapturePayment(ctx context.Context, req CaptureRequest) (*PaymentStatus, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.CapturePayment(ctx, req)
+}
+
+func (s *TenantPaymentSwitcher) VoidPayment(ctx context.Context, req VoidRequest) (*PaymentStatus, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.VoidPayment(ctx, req)
+}
+
+// VerifyWebhookSignature reports whether any tenant accepts the webhook.
+func (s *TenantPaymentSwitcher) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, err := s.VerifyAndParseWebhook(context.Background(), payload, headers)
+	return err == nil
+}
+
+// VerifyAndParseWebhook finds the tenant and gateway whose webhook secret
+// accepts the payload and parses the event with that gateway. Tenants are
+// tried in sorted order; when ctx carries a tenant, only that one is tried.
+// Tenants whose switcher cannot be built are skipped, and their errors are
+// returned alongside ErrWebhookSignatureMismatch if no tenant matches.
+func (s *TenantPaymentSwitcher) VerifyAndParseWebhook(ctx context.Context, payload []byte, headers map[string]string) (*VerifiedWebhook, error) {
+	ids, err := s.tenants.webhookTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var skipped []error
+	for _, tenantID := range ids {
+		sw, err := s.tenants.get(ctx, tenantID)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		wh, err := sw.VerifyAndParseWebhook(WithTenant(ctx, tenantID), payload, headers)
+		if errors.Is(err, ErrWebhookSignatureMismatch) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pg-switcher: tenant %q: %w", tenantID, err)
+		}
+		wh.TenantID = tenantID
+		return wh, nil
+	}
+	return nil, webhookMismatch(skipped)
+}
+
+// ParseWebhookEvent cannot tell which tenant sent the webhook and always
+// fails. Use VerifyAndParseWebhook.
+func (s *TenantPaymentSwitcher) ParseWebhookEvent([]byte) (*WebhookEvent, error) {
+	return nil, fmt.Errorf("pg-switcher: parse webhook event: %w; use VerifyAndParseWebhook", ErrNoTenant)
+}
+
+// ClientCredentials returns an empty map, since no tenant is known. Use
+// Switcher(ctx, tenantID).ClientCredentials() instead.
+func (s *TenantPaymentSwitcher) ClientCredentials() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// ActiveGatewayName resolves and returns the name of the payment gateway the
+// tenant in ctx currently uses.
+func (s *TenantPaymentSwitcher) ActiveGatewayName(ctx context.Context) (string, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return sw.ActiveGatewayName(ctx)
+}
+
+// --- TenantPayoutSwitcher ---
+
+// TenantPayoutSwitcher routes payout calls to a per-tenant
+// DynamicPayoutSwitcher; see TenantPaymentSwitcher.
+type TenantPayoutSwitcher struct {
+	tenants *tenantSet[*DynamicPayoutSwitcher]
+}
+
+// NewTenantPayoutSwitcher creates a TenantPayoutSwitcher. A nil resolver
+// takes the tenant from the context and uses the tenant's PayoutGateway.
+func NewTenantPayoutSwitcher(source TenantConfigSource, resolver TenantGatewayResolver, opts ...SwitcherOption) *TenantPayoutSwitcher {
+	build := func(_ string, cfg Config) (*DynamicPayoutSwitcher, error) {
+		return NewDynamicPayoutSwitcherFromConfig(cfg, tenantDefaultResolver(cfg.PayoutGateway), opts...)
+	}
+	return &TenantPayoutSwitcher{tenants: newTenantSet(source, resolver, build)}
+}
+
+// Switcher returns the switcher of tenantID, building it if needed.
+func (s *TenantPayoutSwitcher) Switcher(ctx context.Context, tenantID string) (*DynamicPayoutSwitcher, error) {
+	return s.tenants.get(ctx, tenantID)
+}
+
+// Invalidate drops the cached switcher of tenantID, so that its next call
+// reloads the tenant's config.
+func (s *TenantPayoutSwitcher) Invalidate(tenantID string) {
+	s.tenants.invalidate(tenantID)
+}
+
+func (s *TenantPayoutSwitcher) Name() string { return "tenant" }
+
+func (s *TenantPayoutSwitcher) CreateContact(ctx context.Context, req CreateContactRequest) (*ContactResponse, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.CreateContact(ctx, req)
+}
+
+func (s *TenantPayoutSwitcher) UpdateContact(ctx context.Context, contactID string, req CreateContactRequest) (*ContactResponse, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.UpdateContact(ctx, contactID, req)
+}
+
+func (s *TenantPayoutSwitcher) CreateFundAccount(ctx context.Context, req CreateFundAccountRequest) (*FundAccountResponse, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.CreateFundAccount(ctx, req)
+}
+
+func (s *TenantPayoutSwitcher) InitiatePayout(ctx context.Context, req InitiatePayoutRequest) (*PayoutResponse, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.InitiatePayout(ctx, req)
+}
+
+func (s *TenantPayoutSwitcher) GetPayoutStatus(ctx context.Context, gatewayPayoutID string) (*PayoutStatusResponse, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sw.GetPayoutStatus(ctx, gatewayPayoutID)
+}
+
+// VerifyWebhookSignature reports whether any tenant accepts the webhook.
+func (s *TenantPayoutSwitcher) VerifyWebhookSignature(payload []byte, headers map[string]string) bool {
+	_, err := s.VerifyAndParseWebhook(context.Background(), payload, headers)
+	return err == nil
+}
+
+// VerifyAndParseWebhook finds the tenant and gateway whose webhook secret
+// accepts the payload and parses the event with that gateway. Tenants are
+// tried in sorted order; when ctx carries a tenant, only that one is tried.
+// Tenants whose switcher cannot be built are skipped, and their errors are
+// returned alongside ErrWebhookSignatureMismatch if no tenant matches.
+func (s *TenantPayoutSwitcher) VerifyAndParseWebhook(ctx context.Context, payload []byte, headers map[string]string) (*VerifiedPayoutWebhook, error) {
+	ids, err := s.tenants.webhookTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var skipped []error
+	for _, tenantID := range ids {
+		sw, err := s.tenants.get(ctx, tenantID)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		wh, err := sw.VerifyAndParseWebhook(WithTenant(ctx, tenantID), payload, headers)
+		if errors.Is(err, ErrWebhookSignatureMismatch) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pg-switcher: tenant %q: %w", tenantID, err)
+		}
+		wh.TenantID = tenantID
+		return wh, nil
+	}
+	return nil, webhookMismatch(skipped)
+}
+
+// ParseWebhookEvent cannot tell which tenant sent the webhook and always
+// fails. Use VerifyAndParseWebhook.
+func (s *TenantPayoutSwitcher) ParseWebhookEvent([]byte) (*PayoutWebhookEvent, error) {
+	return nil, fmt.Errorf("pg-switcher: parse payout webhook event: %w; use VerifyAndParseWebhook", ErrNoTenant)
+}
+
+// IsManual reports false, since no tenant is known. Use
+// Switcher(ctx, tenantID).IsManual() instead.
+func (s *TenantPayoutSwitcher) IsManual() bool { return false }
+
+// ActiveGatewayName resolves and returns the name of the payout gateway the
+// tenant in ctx currently uses.
+func (s *TenantPayoutSwitcher) ActiveGatewayName(ctx context.Context) (string, error) {
+	ctx, sw, err := s.tenants.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return sw.ActiveGatewayName(ctx)
+}
@@ -0,0 +1,109 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tenantGateway is built by the "fake" payment driver from a tenant's Paytm
+// section: MID names the tenant and WebhookSecret signs its webhooks. Like
+// Paytm, it uses the receipt as the order ID.
+type tenantGateway struct {
+	orderGateway
+	mid, secret string
+}
+
+func (g *tenantGateway) Name() string { return "fake" }
+
+func (g *tenantGateway) CreateOrder(_ context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	return &CreateOrderResponse{GatewayOrderID: req.Receipt, Amount: req.Amount, Currency: req.Currency}, nil
+}
+
+func (g *tenantGateway) VerifyWebhookSignature(_ []byte, headers map[string]string) bool {
+	return headers["X-Signature"] == g.secret
+}
+
+func (g *tenantGateway) ParseWebhookEvent([]byte) (*WebhookEvent, error) {
+	return &WebhookEvent{Type: WebhookEventPaymentSuccess, GatewayOrderID: "order_" + g.mid}, nil
+}
+
+func init() {
+	RegisterPaymentDriver("fake", func(cfg Config) (PaymentGateway, error) {
+		if cfg.Paytm.MID == "" {
+			return nil, ErrNotConfigured
+		}
+		return &tenantGateway{mid: cfg.Paytm.MID, secret: cfg.Paytm.WebhookSecret}, nil
+	})
+}
+
+func tenantConfig(mid string) Config {
+	return Config{PaymentGateway: "fake", Paytm: PaytmConfig{MID: mid, MerchantKey: "key", WebhookSecret: "secret_" + mid}}
+}
+
+func TestTenantWebhookFanOut(t *testing.T) {
+	tenants := StaticTenantConfigs{
+		"a":      tenantConfig("a"),
+		"b":      tenantConfig("b"),
+		"broken": {PaymentGateway: "missing"},
+	}
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		signature  string
+		wantTenant string
+		wantErr    []string // substrings of the error, which must match ErrWebhookSignatureMismatch
+	}{
+		{"first tenant", context.Background(), "secret_a", "a", nil},
+		{"tenant after a broken one", context.Background(), "secret_b", "b", nil},
+		{"no match reports skipped tenants", context.Background(), "wrong", "", []string{`tenant "broken"`}},
+		{"tenant in context", WithTenant(context.Background(), "b"), "secret_b", "b", nil},
+		{"other tenant in context", WithTenant(context.Background(), "a"), "secret_b", "", nil},
+		{"broken tenant in context", WithTenant(context.Background(), "broken"), "secret_a", "", []string{`tenant "broken"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewTenantPaymentSwitcher(tenants, nil)
+			wh, err := s.VerifyAndParseWebhook(tt.ctx, []byte(`{}`), map[string]string{"X-Signature": tt.signature})
+			if tt.wantTenant != "" {
+				if err != nil {
+					t.Fatalf("VerifyAndParseWebhook() error = %v", err)
+				}
+				if wh.TenantID != tt.wantTenant || wh.Gateway != "fake" || wh.Event.GatewayOrderID != "order_"+tt.wantTenant {
+					t.Fatalf("VerifyAndParseWebhook() = %+v, want tenant %q", wh, tt.wantTenant)
+				}
+				return
+			}
+			if !errors.Is(err, ErrWebhookSignatureMismatch) {
+				t.Fatalf("VerifyAndParseWebhook() error = %v, want ErrWebhookSignatureMismatch", err)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTenantBindingsAreNamespaced(t *testing.T) {
+	store := NewMemoryBindingStore()
+	tenants := StaticTenantConfigs{"a": tenantConfig("a"), "b/c": tenantConfig("b")}
+	s := NewTenantPaymentSwitcher(tenants, nil, WithBindingStore(store))
+
+	for _, tenantID := range []string{"a", "b/c"} {
+		ctx := WithTenant(context.Background(), tenantID)
+		if _, err := s.CreateOrder(ctx, CreateOrderRequest{Amount: 100, Currency: "INR", Receipt: "bk_1"}); err != nil {
+			t.Fatalf("tenant %q: %v", tenantID, err)
+		}
+	}
+	for _, id := range []string{"a/bk_1", "b%2Fc/bk_1"} {
+		if gw, ok, _ := store.Lookup(context.Background(), BindingOrder, id); !ok || gw != "fake" {
+			t.Errorf("Lookup(%q) = %q, %v; want a binding to fake", id, gw, ok)
+		}
+	}
+	if _, ok, _ := store.Lookup(context.Background(), BindingOrder, "bk_1"); ok {
+		t.Error("order bound without a tenant prefix")
+	}
+}
@@ -0,0 +1,48 @@
+package pg
+
+import "time"
+
+// DefaultWebhookSecretID is reported when the single WebhookSecret field of a
+// gateway config (or its secret provider value) verified a webhook.
+const DefaultWebhookSecretID = "default"
+
+// WebhookSecret is one of several secrets a gateway accepts webhook
+// signatures from, so that a secret can be rotated without rejecting
+// webhooks signed with either the old or the new one.
+type WebhookSecret struct {
+	ID        string    `json:"id" yaml:"id"` // reported when this secret matches, e.g. "2024-06"
+	Value     string    `json:"value" yaml:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"` // zero means no expiry
+}
+
+// WebhookSecretVerifier is implemented by adapters that accept several
+// webhook secrets. VerifyWebhook reports the ID of the secret that matched.
+type WebhookSecretVerifier interface {
+	VerifyWebhook(payload []byte, headers map[string]string) (secretID string, ok bool)
+}
+
+// ActiveWebhookSecrets returns the secrets to try at now: primary (under
+// DefaultWebhookSecretID) when set, followed by the entries of more that have
+// a value and have not expired. Adapters use it to build their candidate list.
+func ActiveWebhookSecrets(primary string, more []WebhookSecret, now time.Time) []WebhookSecret {
+	out := make([]WebhookSecret, 0, len(more)+1)
+	if primary != "" {
+		out = append(out, WebhookSecret{ID: DefaultWebhookSecretID, Value: primary})
+	}
+	for _, s := range more {
+		if s.Value == "" || (!s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)) {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// verifyWebhookWithID runs gw's signature check, returning the matching
+// secret's ID when gw implements WebhookSecretVerifier.
+func verifyWebhookWithID(gw webhookVerifier, payload []byte, headers map[string]string) (string, bool) {
+	if v, ok := gw.(WebhookSecretVerifier); ok {
+		return v.VerifyWebhook(payload, headers)
+	}
+	return "", gw.VerifyWebhookSignature(payload, headers)
+}
@@ -0,0 +1,118 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+type routingKeyCtxKey struct{}
+
+// WithRoutingKey returns a context carrying key (e.g. a user ID) for
+// deterministic traffic splitting by WeightedResolver.
+func WithRoutingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, routingKeyCtxKey{}, key)
+}
+
+// RoutingKeyFromContext returns the key set by WithRoutingKey, or "".
+func RoutingKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(routingKeyCtxKey{}).(string)
+	return key
+}
+
+type gatewayWeight struct {
+	name   string
+	weight int
+}
+
+// WeightedResolver splits traffic across gateways in proportion to their
+// weights. Requests with a routing key are assigned deterministically, so the
+// same customer keeps seeing the same gateway; requests without one are
+// assigned at random. Weights may be changed at runtime with SetWeights.
+//
+// Gateways are laid out in name order on a fixed scale, so a small change in
+// weights only moves a correspondingly small share of keys.
+type WeightedResolver struct {
+	key func(ctx context.Context) string
+
+	mu      sync.RWMutex
+	weights []gatewayWeight
+	total   int
+}
+
+// NewWeightedResolver creates a WeightedResolver. key extracts the routing key
+// from the context; when nil, RoutingKeyFromContext is used.
+func NewWeightedResolver(weights map[string]int, key func(ctx context.Context) string) (*WeightedResolver, error) {
+	if key == nil {
+		key = RoutingKeyFromContext
+	}
+	r := &WeightedResolver{key: key}
+	if err := r.SetWeights(weights); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetWeights atomically replaces the weights. Weights must be non-negative
+// and at least one must be positive.
+func (r *WeightedResolver) SetWeights(weights map[string]int) error {
+	list := make([]gatewayWeight, 0, len(weights))
+	total := 0
+	for name, w := range weights {
+		if w < 0 {
+			return fmt.Errorf("pg-switcher: negative weight %d for gateway %q", w, name)
+		}
+		if w > 0 {
+			list = append(list, gatewayWeight{name: name, weight: w})
+			total += w
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("pg-switcher: weighted resolver needs at least one positive weight")
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.weights = list
+	r.total = total
+	return nil
+}
+
+// Weights returns a copy of the current weights.
+func (r *WeightedResolver) Weights() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]int, len(r.weights))
+	for _, w := range r.weights {
+		out[w.name] = w.weight
+	}
+	return out
+}
+
+// Resolve picks a gateway. Its signature matches GatewayResolver, so it can be
+// passed directly as r.Resolve.
+func (r *WeightedResolver) Resolve(ctx context.Context) (string, error) {
+	var point float64
+	if key := r.key(ctx); key != "" {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		point = float64(h.Sum64()%1_000_000) / 1_000_000
+	} else {
+		point = rand.Float64()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cumulative := 0.0
+	for _, w := range r.weights {
+		cumulative += float64(w.weight) / float64(r.total)
+		if point < cumulative {
+			return w.name, nil
+		}
+	}
+	return r.weights[len(r.weights)-1].name, nil
+}
@@ -0,0 +1,150 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestWeightedResolverSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+		keyed   bool
+	}{
+		{"keyed 70/30", map[string]int{"razorpay": 70, "paytm": 30}, true},
+		{"random 70/30", map[string]int{"razorpay": 70, "paytm": 30}, false},
+		{"keyed thirds", map[string]int{"a": 1, "b": 1, "c": 1}, true},
+		{"zero weight gets nothing", map[string]int{"razorpay": 1, "paytm": 0}, true},
+	}
+	const n = 20000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewWeightedResolver(tt.weights, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total := 0
+			for _, w := range tt.weights {
+				total += w
+			}
+			counts := make(map[string]int)
+			for i := 0; i < n; i++ {
+				ctx := context.Background()
+				if tt.keyed {
+					ctx = WithRoutingKey(ctx, fmt.Sprintf("user_%d", i))
+				}
+				name, err := r.Resolve(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				counts[name]++
+			}
+			for name, w := range tt.weights {
+				want := float64(w) / float64(total)
+				if got := float64(counts[name]) / n; math.Abs(got-want) > 0.03 {
+					t.Errorf("%s got %.3f of traffic, want %.3f", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedResolverIsSticky(t *testing.T) {
+	r, err := NewWeightedResolver(map[string]int{"razorpay": 50, "paytm": 50}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithRoutingKey(context.Background(), "user_42")
+	first, _ := r.Resolve(ctx)
+	for i := 0; i < 100; i++ {
+		if got, _ := r.Resolve(ctx); got != first {
+			t.Fatalf("Resolve() = %q, then %q for the same key", first, got)
+		}
+	}
+
+	// Shifting 10 points of weight moves about 10% of keys, not half of them
+	before := make([]string, 10000)
+	for i := range before {
+		before[i], _ = r.Resolve(WithRoutingKey(context.Background(), fmt.Sprintf("user_%d", i)))
+	}
+	if err := r.SetWeights(map[string]int{"razorpay": 40, "paytm": 60}); err != nil {
+		t.Fatal(err)
+	}
+	moved := 0
+	for i := range before {
+		if got, _ := r.Resolve(WithRoutingKey(context.Background(), fmt.Sprintf("user_%d", i))); got != before[i] {
+			moved++
+		}
+	}
+	if frac := float64(moved) / float64(len(before)); frac > 0.13 {
+		t.Fatalf("%.1f%% of keys moved after a 10-point weight change", 100*frac)
+	}
+}
+
+func TestWeightedResolverCustomKey(t *testing.T) {
+	r, err := NewWeightedResolver(map[string]int{"razorpay": 1, "paytm": 1}, func(ctx context.Context) string {
+		req, _ := OrderRequestFromContext(ctx)
+		return req.Receipt
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := withOrderRequest(context.Background(), CreateOrderRequest{Receipt: "bk_1"})
+	first, _ := r.Resolve(ctx)
+	for i := 0; i < 20; i++ {
+		if got, _ := r.Resolve(ctx); got != first {
+			t.Fatalf("Resolve() = %q, then %q for the same receipt", first, got)
+		}
+	}
+}
+
+func TestWeightedResolverSetWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+		ok      bool
+	}{
+		{"valid", map[string]int{"razorpay": 3, "paytm": 1}, true},
+		{"one positive", map[string]int{"razorpay": 1, "paytm": 0}, true},
+		{"negative", map[string]int{"razorpay": 1, "paytm": -1}, false},
+		{"all zero", map[string]int{"razorpay": 0}, false},
+		{"empty", map[string]int{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewWeightedResolver(map[string]int{"razorpay": 1}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = r.SetWeights(tt.weights)
+			if tt.ok != (err == nil) {
+				t.Fatalf("SetWeights(%v) = %v, want ok=%v", tt.weights, err, tt.ok)
+			}
+			want := map[string]int{"razorpay": 1}
+			if tt.ok {
+				want = tt.weights
+			}
+			got := r.Weights()
+			for name, w := range want {
+				if got[name] != w {
+					t.Fatalf("Weights() = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedResolverWeightsIsACopy(t *testing.T) {
+	r, err := NewWeightedResolver(map[string]int{"razorpay": 1, "paytm": 0}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Weights()["paytm"] = 100
+	for i := 0; i < 100; i++ {
+		if got, _ := r.Resolve(context.Background()); got != "razorpay" {
+			t.Fatalf("Resolve() = %q after mutating the Weights() result", got)
+		}
+	}
+}